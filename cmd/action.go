@@ -1,97 +1,167 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"micli/internal/conf"
 	"micli/pkg/miservice"
 	"micli/pkg/util"
 
 	"github.com/pterm/pterm"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
 var actionCmd = &cobra.Command{
-	Use:   "action <siid[-piid]> <arg1> [...] ",
+	Use:   "action <siid[-aiid]|service.action> <arg1> [...] ",
 	Short: "MIoT Action",
 	Long:  `MIoT Action`,
-	Run: func(cmd *cobra.Command, args []string) {
-		var (
-			res interface{}
-			err error
-		)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
 		if len(args) < 1 {
-			err = fmt.Errorf("no args found")
-			pterm.Error.Println(err.Error())
-			return
+			return errors.New("no args found")
+		}
+		var devices []*miservice.DeviceInfo
+		devices, err = selectDevices()
+		if err != nil {
+			return err
+		}
+		if devices != nil {
+			return runBatch(devices, func(device *miservice.DeviceInfo) (interface{}, string, error) {
+				view, err := runAction(device, args)
+				if view == nil || err != nil {
+					return view, "", err
+				}
+				summary := make([]string, 0, len(view.Out)+1)
+				summary = append(summary, "success")
+				for _, o := range view.Out {
+					summary = append(summary, fmt.Sprintf("%s=%v", lo.CoalesceOrEmpty(o.Description, o.Name, fmt.Sprint(o.Piid)), o.Value))
+				}
+				return view, strings.Join(summary, ", "), nil
+			})
 		}
 		if did == "" {
-			did = conf.Cfg.Section("account").Key("MI_DID").MustString("")
+			did = conf.Account().Key("MI_DID").MustString("")
 			if did == "" {
 				did, err = chooseDevice()
 				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
+					return err
 				}
 			}
 		}
-		if !util.IsDigit(did) {
-			var devices []*miservice.DeviceInfo
-			devices, err = getDeviceListFromLocal() // Implement this method for the IOService
-			if err != nil {
-				pterm.Error.Println(err.Error())
-				return
-			}
-			if len(devices) == 0 {
-				err = fmt.Errorf("no device found")
-				pterm.Error.Println(err.Error())
-				return
-			}
-			for _, device := range devices {
-				if device.Name == did {
-					did = device.Did
-					break
-				}
-			}
+		var device *miservice.DeviceInfo
+		device, err = resolveDevice(did)
+		if err != nil {
+			return err
 		}
-		miot := true
-		siid, iid := util.TwinsSplit(args[0], "-", "1")
-		var prop []interface{}
-		if util.IsDigit(siid) && util.IsDigit(iid) {
-			s, _ := strconv.Atoi(siid)
-			i, _ := strconv.Atoi(iid)
-			prop = []interface{}{s, i}
-		} else {
-			miot = false
+		view, err := runAction(device, args)
+		// a failed action is still a result worth printing
+		if view == nil {
+			return err
 		}
-		if miot {
-			var _args []interface{}
-			if len(args) > 1 {
-				for _, a := range args[1:] {
-					_args = append(_args, util.StringOrValue(a))
-				}
+		if renderErr := render(view, func() error {
+			if view.Code == 0 {
+				pterm.NewStyle(pterm.FgGreen).Println("success.")
+			} else {
+				pterm.NewStyle(pterm.FgRed).Printf("failed: %s (%d)\n", view.Reason, view.Code)
 			}
-			var ids []int
-			for _, id := range prop {
-				if v, ok := id.(int); ok {
-					ids = append(ids, v)
-				} else if v, ok := id.(string); ok {
-					if v2, err := strconv.Atoi(v); err == nil {
-						ids = append(ids, v2)
-					}
-				}
+			if len(view.Out) == 0 {
+				return nil
 			}
-			res, err = ioSrv.MiotAction(did, ids, _args)
-			if res.(float64) == 0 {
-				res = "success."
+			data := pterm.TableData{{"Piid", "Name", "Value", "Description"}}
+			for _, o := range view.Out {
+				name := o.Description
+				if name == "" {
+					name = o.Name
+				}
+				data = append(data, []string{fmt.Sprint(o.Piid), name, fmt.Sprint(o.Value), o.Text})
 			}
+			return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+		}); renderErr != nil {
+			return renderErr
 		}
-		handleResult(res, err)
+		return err
 	},
 }
 
+// runAction resolves and validates the action in args against the spec of a
+// device and runs it. A failed action still returns its result.
+func runAction(device *miservice.DeviceInfo, args []string) (*actionView, error) {
+	var (
+		ids   []int
+		specs *miservice.MiotSpecInstancesData
+		err   error
+	)
+	siid, iid := util.TwinsSplit(args[0], "-", "1")
+	if util.IsDigit(siid) && util.IsDigit(iid) {
+		s, _ := strconv.Atoi(siid)
+		i, _ := strconv.Atoi(iid)
+		ids = []int{s, i}
+	} else {
+		// names need the spec even with --force
+		specs, err = ioSrv.MiotSpec(device.Model)
+		if err != nil {
+			return nil, err
+		}
+		_service, _action, err := specs.ResolveAction(args[0])
+		if err != nil {
+			return nil, err
+		}
+		ids = []int{_service.Iid, _action.Iid}
+	}
+	var _args []interface{}
+	// "#NA" stands for an action without arguments
+	if len(args) > 1 && args[1] != "#NA" {
+		for _, a := range args[1:] {
+			_args = append(_args, util.StringOrValue(a))
+		}
+	}
+	if force {
+		specs = nil
+	} else if specs == nil {
+		specs = loadDeviceSpec(device.Model)
+	}
+	_args, err = validateAction(specs, ids[0], ids[1], _args)
+	if err != nil {
+		return nil, err
+	}
+	useLocal(device.Did)
+	res, err := ioSrv.MiotAction(device.Did, ids, _args)
+	if res == nil {
+		return nil, err
+	}
+	var outputs []*miservice.ActionOutput
+	if len(res.Out) > 0 {
+		// outputs are named by the spec even with --force
+		if specs == nil {
+			specs, _ = ioSrv.MiotSpec(device.Model)
+		}
+		var service *miservice.MiotSpecService
+		if specs != nil {
+			service = specs.Service(ids[0])
+		}
+		outputs = service.ActionOutputs(ids[1], res.Out)
+	}
+	return &actionView{Did: res.Did, Siid: res.Siid, Aiid: res.Aiid, Code: res.Code, Reason: res.Reason, Out: outputs}, err
+}
+
+// actionView is an action result with its out values named by the spec
+type actionView struct {
+	Did    string                    `json:"did"`
+	Siid   int                       `json:"siid"`
+	Aiid   int                       `json:"aiid"`
+	Code   int                       `json:"code"`
+	Reason string                    `json:"reason,omitempty"`
+	Out    []*miservice.ActionOutput `json:"out,omitempty"`
+}
+
 func init() {
-	actionCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID")
-	actionCmd.Example = "  action 2 #NA\n  action 5 Hello #1\n  action 5-4 Hello 0"
+	actionCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID, name or room/name, comma separated for several devices")
+	addLocalFlags(actionCmd)
+	addSelectorFlags(actionCmd)
+	addValidateFlags(actionCmd)
+	actionCmd.Example = "  action 2 #NA\n  action 5 Hello #1\n  action 5-4 Hello 0\n  action intelligent-speaker.play-text \"hi\""
 }
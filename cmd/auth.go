@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"micli/internal"
+	"micli/internal/conf"
+	"micli/internal/handlers"
+	"micli/pkg/miservice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"rsc.io/qr"
+)
+
+// tokenRefreshInterval is how often long-running commands look for stale tokens
+const tokenRefreshInterval = 10 * time.Minute
+
+// tokenRefreshAge returns [token] REFRESH_AGE, the age at which service tokens are renewed
+func tokenRefreshAge() time.Duration {
+	return conf.Cfg.Section("token").Key("REFRESH_AGE").MustDuration(miservice.DefaultTokenRefreshAge)
+}
+
+// authStatus is the session printed by `auth status`
+type authStatus struct {
+	Profile string `json:"profile"`
+	Store   string `json:"store"`
+	*miservice.AuthStatus
+}
+
+var (
+	authRefresh  bool
+	authQRFile   string
+	authQRHTTP   string
+	authPassword bool
+	authCmd      = &cobra.Command{
+		Use:   "auth",
+		Short: "Log in and inspect the login session",
+		Long:  `Log in and inspect the login session`,
+	}
+	authLoginCmd = &cobra.Command{
+		Use:   "login",
+		Short: "Log in with a QR code scanned in the Mi Home app",
+		Long: `Log in with a QR code scanned in the Mi Home app. The saved token is only
+replaced once the login succeeds, a session that is still valid is kept.
+The code is printed on the terminal, or for headless machines written to a
+PNG with --qr-file and served at http://<addr>/login with --qr-http.
+--password logs in with MI_PASS instead, asking for a captcha or an SMS or
+email code when Xiaomi wants one.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var show []miservice.QRHandler
+			if authPassword {
+				if authQRFile != "" || authQRHTTP != "" {
+					return fmt.Errorf("--password can not be combined with --qr-file or --qr-http")
+				}
+			} else {
+				if authQRFile != "" {
+					show = append(show, fileQR(authQRFile))
+					defer os.Remove(authQRFile)
+				}
+				if authQRHTTP != "" {
+					srv, err := serveLoginQR(authQRHTTP)
+					if err != nil {
+						return err
+					}
+					defer srv.Close()
+					show = append(show, webQR)
+				}
+				if len(show) == 0 {
+					if err := conf.RequirePrompt("the QR code needs --qr-file or --qr-http without a terminal or with --no-interactive"); err != nil {
+						return err
+					}
+					show = append(show, miservice.TerminalQR)
+				}
+			}
+
+			tokenStore, err := newTokenStore()
+			if err != nil {
+				return err
+			}
+			pass, err := conf.Password()
+			if err != nil && authPassword {
+				return err
+			}
+			srv := miservice.New(
+				conf.Account().Key("MI_USER").MustString(""),
+				pass,
+				conf.Account().Key("REGION").MustString("cn"),
+				tokenStore,
+			)
+			srv.SetInteractive(conf.CanPrompt())
+			srv.SetCaptchaHandler(promptCaptcha)
+			srv.SetVerifyHandler(promptVerifyCode)
+			srv.SetQRHandler(func(loginURL, imageURL string) error {
+				for _, h := range show {
+					if err := h(loginURL, imageURL); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+			mode := "QR"
+			if authPassword {
+				mode = "password"
+				err = srv.PasswordLogin(miservice.MiioSid)
+			} else {
+				_, err = srv.QRLogin()
+				handlers.SetLoginQR(nil)
+			}
+			if err != nil {
+				if errors.Is(err, miservice.ErrNetwork) {
+					return fmt.Errorf("%s login failed: %w", mode, err)
+				}
+				return fmt.Errorf("%w: %s login failed: %v", miservice.ErrAuthExpired, mode, err)
+			}
+			pterm.Success.Println("Login successful!")
+			pterm.Info.Printf("User ID: %s\n", srv.Status().UserId)
+			return nil
+		},
+	}
+	authStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the logged-in account and its tokens",
+		Long: `Show the logged-in account, region, login mode and the service tokens
+held with their age. Tokens older than [token] REFRESH_AGE are marked stale,
+--refresh renews them all with the passToken without any prompt.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status := ms.Status()
+			if authRefresh && status.LoggedIn {
+				sids := make([]string, 0, len(status.Sids))
+				for _, s := range status.Sids {
+					sids = append(sids, s.Sid)
+				}
+				if len(sids) == 0 {
+					sids = []string{miservice.MiioSid, miservice.MinaSid}
+				}
+				for _, sid := range sids {
+					if err := ms.RefreshSid(sid); err != nil {
+						return fmt.Errorf("refresh %s: %w", sid, err)
+					}
+				}
+				status = ms.Status()
+			}
+			data := &authStatus{
+				Profile:    conf.DefaultProfile,
+				Store:      conf.TokenBackend(),
+				AuthStatus: status,
+			}
+			if conf.Profile != "" {
+				data.Profile = conf.Profile
+			}
+			err := render(data, func() error {
+				state := pterm.Red("not logged in")
+				if status.LoggedIn {
+					state = pterm.Green("logged in")
+				}
+				info := pterm.TableData{
+					{"Profile", data.Profile},
+					{"User", status.User},
+					{"User ID", status.UserId},
+					{"Region", status.Region},
+					{"Status", state},
+					{"Login mode", status.LoginMode},
+					{"passToken", fmt.Sprint(status.PassToken)},
+					{"Token store", data.Store},
+				}
+				if err := pterm.DefaultTable.WithData(info).Render(); err != nil {
+					return err
+				}
+				if len(status.Sids) == 0 {
+					return nil
+				}
+				maxAge := tokenRefreshAge()
+				sids := pterm.TableData{{"Sid", "Issued", "Age", ""}}
+				for _, s := range status.Sids {
+					issued, age, mark := "unknown", "unknown", pterm.Yellow("stale")
+					if s.IssuedAt != nil {
+						d := time.Duration(s.Age) * time.Second
+						issued, age = s.IssuedAt.Format(time.DateTime), d.String()
+						if d < maxAge {
+							mark = pterm.Green("fresh")
+						}
+					}
+					sids = append(sids, []string{s.Sid, issued, age, mark})
+				}
+				pterm.Println()
+				return pterm.DefaultTable.WithHasHeader().WithData(sids).Render()
+			})
+			if err != nil {
+				return err
+			}
+			if !status.LoggedIn {
+				return fmt.Errorf("%w: no session for %s, run any command to log in", miservice.ErrAuthExpired, status.User)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	authLoginCmd.Flags().StringVar(&authQRFile, "qr-file", "", "write the QR code to this PNG file instead of the terminal")
+	authLoginCmd.Flags().StringVar(&authQRHTTP, "qr-http", "", "serve the QR code at http://<addr>/login, e.g. :9000")
+	authLoginCmd.Flags().BoolVar(&authPassword, "password", false, "log in with the password instead of a QR code")
+	authStatusCmd.Flags().BoolVar(&authRefresh, "refresh", false, "renew every service token with the passToken")
+	authCmd.AddCommand(authLoginCmd, authStatusCmd)
+	authCmd.Example = "  auth login\n  auth login --qr-file /tmp/login.png\n  auth login --qr-http :9000 --no-interactive\n  auth login --password\n  auth status"
+}
+
+// promptCaptcha saves the captcha image of a password login and asks for its text
+func promptCaptcha(image []byte) (string, error) {
+	if err := conf.RequirePrompt("the login asks for a captcha"); err != nil {
+		return "", err
+	}
+	ext := ".jpg"
+	if http.DetectContentType(image) == "image/png" {
+		ext = ".png"
+	}
+	f, err := os.CreateTemp("", "micli-captcha-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(image)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	pterm.Warning.Printf("Xiaomi asks for a captcha, open %s\n", f.Name())
+	return pterm.DefaultInteractiveTextInput.Show("Enter the captcha")
+}
+
+// promptVerifyCode asks for the code Xiaomi sent to confirm a login from a new device
+func promptVerifyCode(method string) (string, error) {
+	if err := conf.RequirePrompt("the login asks for a code sent by %s", method); err != nil {
+		return "", err
+	}
+	pterm.Info.Printf("Xiaomi sent a verification code to the %s of your account\n", method)
+	return pterm.DefaultInteractiveTextInput.Show("Enter the verification code")
+}
+
+// fileQR writes the QR code to a PNG file
+func fileQR(path string) miservice.QRHandler {
+	return func(loginURL, imageURL string) error {
+		png, err := qrPNG(loginURL)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(path, png, 0o600); err != nil {
+			return err
+		}
+		pterm.Info.Printf("QR code written to %s, scan it with the Mi Home app\n", path)
+		return nil
+	}
+}
+
+// webQR publishes the QR code on /login of the gin app
+func webQR(loginURL, imageURL string) error {
+	png, err := qrPNG(loginURL)
+	if err != nil {
+		return err
+	}
+	handlers.SetLoginQR(png)
+	return nil
+}
+
+func qrPNG(content string) ([]byte, error) {
+	code, err := qr.Encode(content, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(), nil
+}
+
+// serveLoginQR starts a gin app with the login page only
+func serveLoginQR(addr string) (*http.Server, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --qr-http address %q: %w", addr, err)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	gin.SetMode(gin.ReleaseMode)
+	app := internal.NewApp(addr)
+	app.RegisterMiddlewares()
+	app.RegisterLoginRoutes()
+	srv := &http.Server{Handler: app}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			pterm.Error.Printf("Login page stopped: %v\n", err)
+		}
+	}()
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	pterm.Info.Printf("Open http://%s/login to scan the QR code\n", net.JoinHostPort(host, port))
+	return srv, nil
+}
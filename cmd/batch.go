@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selModel     string
+	selRoom      string
+	selNameMatch string
+	parallel     int
+)
+
+// addSelectorFlags registers the flags that pick several devices at once, see selectDevices
+func addSelectorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&selModel, "model", "", "run on every device whose model matches the glob, e.g. 'yeelink.light.*'")
+	cmd.Flags().StringVar(&selRoom, "room", "", "run on every device in the room")
+	cmd.Flags().StringVar(&selNameMatch, "name-match", "", "run on every device whose name contains the text")
+	cmd.Flags().IntVar(&parallel, "parallel", 4, "devices handled at the same time")
+}
+
+// selectDevices returns the devices picked by a comma separated --did list
+// and the --model, --room and --name-match filters, or nil when a single
+// device is addressed.
+func selectDevices() ([]*miservice.DeviceInfo, error) {
+	if selModel == "" && selRoom == "" && selNameMatch == "" && !strings.Contains(did, ",") {
+		return nil, nil
+	}
+	var (
+		devices []*miservice.DeviceInfo
+		err     error
+	)
+	if did != "" {
+		for _, s := range strings.Split(did, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			var device *miservice.DeviceInfo
+			if device, err = resolveDevice(s); err != nil {
+				return nil, err
+			}
+			devices = append(devices, device)
+		}
+	} else if devices, err = getDeviceListFromLocal(); err != nil {
+		return nil, err
+	}
+	if selModel != "" {
+		if _, err = path.Match(selModel, ""); err != nil {
+			return nil, fmt.Errorf("invalid --model pattern: %w", err)
+		}
+		devices = lo.Filter(devices, func(d *miservice.DeviceInfo, _ int) bool {
+			ok, _ := path.Match(selModel, d.Model)
+			return ok
+		})
+	}
+	if selRoom != "" {
+		rooms := deviceRooms()
+		devices = lo.Filter(devices, func(d *miservice.DeviceInfo, _ int) bool { return strings.EqualFold(rooms[d.Did], selRoom) })
+	}
+	if selNameMatch != "" {
+		match := strings.ToLower(selNameMatch)
+		devices = lo.Filter(devices, func(d *miservice.DeviceInfo, _ int) bool { return strings.Contains(strings.ToLower(d.Name), match) })
+	}
+	devices = lo.UniqBy(devices, func(d *miservice.DeviceInfo) string { return d.Did })
+	if len(devices) == 0 {
+		return nil, errors.New("no device matches the selection")
+	}
+	return devices, nil
+}
+
+// batchResult is the outcome of an operation on one device of a batch
+type batchResult struct {
+	Did    string      `json:"did"`
+	Name   string      `json:"name"`
+	Model  string      `json:"model"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+
+	summary string
+}
+
+// batchError is returned when an operation failed on some devices of a batch
+type batchError struct {
+	failed int
+	total  int
+	err    error
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("%d of %d devices failed, first error: %v", e.failed, e.total, e.err)
+}
+
+func (e *batchError) Unwrap() error {
+	return e.err
+}
+
+// batchOp runs an operation on one device and returns its result and a one line summary
+type batchOp func(device *miservice.DeviceInfo) (result interface{}, summary string, err error)
+
+// runBatch runs op on every device, at most --parallel at a time, and prints a per device table
+func runBatch(devices []*miservice.DeviceInfo, op batchOp) error {
+	results := make([]*batchResult, len(devices))
+	errs := make([]error, len(devices))
+	sem := make(chan struct{}, max(parallel, 1))
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res := &batchResult{Did: device.Did, Name: device.Name, Model: device.Model}
+			res.Result, res.summary, errs[i] = op(device)
+			if errs[i] != nil {
+				res.Error = errs[i].Error()
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	if err := render(results, func() error {
+		data := pterm.TableData{{"Device", "Did", "Model", "Result"}}
+		for _, r := range results {
+			res := pterm.Green(r.summary)
+			if r.Error != "" {
+				res = pterm.Red(r.Error)
+			}
+			data = append(data, []string{r.Name, r.Did, r.Model, res})
+		}
+		return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+	}); err != nil {
+		return err
+	}
+	failed := lo.Compact(errs)
+	if len(failed) == 0 {
+		return nil
+	}
+	return &batchError{failed: len(failed), total: len(devices), err: failed[0]}
+}
@@ -8,7 +8,7 @@ var decodeCmd = &cobra.Command{
 	Use:   "decode <ssecurity> <nonce> <data> [gzip]",
 	Short: "MIoT Decode",
 	Long:  `MIoT Decode`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		argLen := len(args)
 		var (
 			arg0, arg1, arg2, arg3 string
@@ -30,6 +30,6 @@ var decodeCmd = &cobra.Command{
 		gzipFlag := arg3 == "gzip"
 		res, err = ioSrv.MiotDecode(arg0, arg1, arg2, gzipFlag)
 
-		handleResult(res, err)
+		return handleResult(res, err)
 	},
 }
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"micli/pkg/miio"
+	"micli/pkg/miservice"
+	"micli/pkg/util"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// DiscoveredDevice is a cached LAN discovery result
+type DiscoveredDevice struct {
+	Did       string `json:"did"`
+	Name      string `json:"name"`
+	Model     string `json:"model"`
+	IP        string `json:"ip"`
+	Stamp     uint32 `json:"stamp"`
+	Reachable bool   `json:"reachable"`
+	Source    string `json:"source"`
+	SeenAt    int64  `json:"seen_at"`
+}
+
+var (
+	discoveryPath    = "./data/discovery.json"
+	discoverTimeout  time.Duration
+	discoverWithMDNS bool
+	discoverCmd      = &cobra.Command{
+		Use:   "discover",
+		Short: "Discover devices on the LAN",
+		Long: `Discover devices on the LAN with the miIO hello broadcast and mDNS (_miio._udp).
+Replies are matched to the cloud device list by DID and saved to the device cache,
+so --local and [route] can use the address without a cloud lookup.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			devices, err := getDeviceListFromLocal()
+			if err != nil {
+				return err
+			}
+			var found []*DiscoveredDevice
+			found, err = discoverDevices(devices)
+			if err != nil {
+				return err
+			}
+			err = writeDiscovery(found)
+			if err != nil {
+				return err
+			}
+			err = render(found, func() error {
+				data := pterm.TableData{{"Name", "DID", "Model", "IP", "Stamp", "Reachable"}}
+				for _, d := range found {
+					ip, stamp := "-", "-"
+					if d.IP != "" {
+						ip = d.IP
+					}
+					if d.Stamp > 0 {
+						stamp = fmt.Sprint(d.Stamp)
+					}
+					reachable := pterm.Red("no")
+					if d.Reachable {
+						reachable = pterm.Green("yes")
+					}
+					data = append(data, []string{d.Name, d.Did, d.Model, ip, stamp, reachable})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+			return err
+		},
+	}
+)
+
+func init() {
+	discoverCmd.Flags().DurationVarP(&discoverTimeout, "timeout", "t", 3*time.Second, "how long to wait for replies")
+	discoverCmd.Flags().BoolVar(&discoverWithMDNS, "mdns", true, "also listen for mDNS _miio._udp announcements")
+}
+
+// discoverDevices runs the hello broadcast and the mDNS query, then matches replies to the cloud device list
+func discoverDevices(devices []*miservice.DeviceInfo) ([]*DiscoveredDevice, error) {
+	var (
+		wg                sync.WaitGroup
+		hellos, mdns      []*miio.Reply
+		helloErr, mdnsErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hellos, helloErr = miio.Discover(discoverTimeout)
+	}()
+	if discoverWithMDNS {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mdns, mdnsErr = miio.DiscoverMDNS(discoverTimeout)
+		}()
+	}
+	wg.Wait()
+	if helloErr != nil {
+		return nil, helloErr
+	}
+	if mdnsErr != nil {
+		pterm.Warning.Printf("mDNS discovery failed: %v\n", mdnsErr)
+	}
+
+	replies := make(map[string]*miio.Reply, len(hellos))
+	for _, r := range hellos {
+		replies[r.Did] = r
+	}
+	for _, r := range mdns {
+		if _, ok := replies[r.Did]; ok {
+			continue
+		}
+		// mDNS only tells us the device exists, confirm it answers miIO
+		if h, err := miio.Hello(r.IP, time.Second); err == nil {
+			r.Stamp = h.Stamp
+		}
+		replies[r.Did] = r
+	}
+
+	now := time.Now().Unix()
+	found := make([]*DiscoveredDevice, 0, len(devices))
+	for _, device := range devices {
+		d := &DiscoveredDevice{Did: device.Did, Name: device.Name, Model: device.Model}
+		if r, ok := replies[device.Did]; ok {
+			d.IP = r.IP
+			d.Stamp = r.Stamp
+			d.Reachable = r.Stamp > 0
+			d.Source = r.Source
+			d.SeenAt = now
+			delete(replies, device.Did)
+		}
+		found = append(found, d)
+	}
+	// devices that answered but are not in the cloud list (e.g. other accounts)
+	for _, r := range replies {
+		found = append(found, &DiscoveredDevice{
+			Did:       r.Did,
+			IP:        r.IP,
+			Stamp:     r.Stamp,
+			Reachable: r.Stamp > 0,
+			Source:    r.Source,
+			SeenAt:    now,
+		})
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Reachable && !found[j].Reachable })
+	return found, nil
+}
+
+func writeDiscovery(list []*DiscoveredDevice) (err error) {
+	var f *os.File
+	f, err = util.CreatNestedFile(discoveryPath)
+	if err != nil {
+		return
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	err = json.NewEncoder(f).Encode(list)
+	return
+}
+
+// getDiscoveryFromLocal loads the last discovery results, a missing file is not an error
+func getDiscoveryFromLocal() (list []*DiscoveredDevice, err error) {
+	if !util.Exists(discoveryPath) {
+		return
+	}
+	var f *os.File
+	f, err = os.Open(discoveryPath)
+	if err != nil {
+		return
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	err = json.NewDecoder(f).Decode(&list)
+	return
+}
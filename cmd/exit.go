@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+
+	"micli/internal/conf"
+	"micli/pkg/miio"
+	"micli/pkg/miservice"
+)
+
+// Exit codes, documented in the README
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitAuth
+	exitOffline
+	exitMiot
+	exitRateLimited
+	exitNetwork
+	exitPartial
+)
+
+// commandStarted is set once flags and arguments have been accepted, errors
+// before that point are usage errors
+var commandStarted bool
+
+// exitStatus maps an error returned by a command to the process exit code
+func exitStatus(err error) int {
+	var (
+		miotErr  *miservice.MiotError
+		batchErr *batchError
+	)
+	switch {
+	case err == nil:
+		return exitOK
+	case !commandStarted, errors.Is(err, conf.ErrNoInteractive):
+		return exitUsage
+	case errors.As(err, &batchErr) && batchErr.failed < batchErr.total:
+		return exitPartial
+	case errors.Is(err, miservice.ErrAuthExpired):
+		return exitAuth
+	case errors.Is(err, miservice.ErrDeviceOffline), errors.Is(err, miio.ErrTimeout):
+		return exitOffline
+	case errors.As(err, &miotErr):
+		return exitMiot
+	case errors.Is(err, miservice.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, miservice.ErrNetwork):
+		return exitNetwork
+	default:
+		return exitError
+	}
+}
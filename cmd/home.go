@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"micli/pkg/miservice"
+	"micli/pkg/util"
+
+	"github.com/pterm/pterm"
+	"github.com/pterm/pterm/putils"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	homesPath = "./data/homes.json"
+	homeCmd   = &cobra.Command{
+		Use:   "home",
+		Short: "Homes and rooms",
+		Long:  `Homes and rooms`,
+	}
+	homeListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List homes",
+		Long:  `List homes`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			homes, err := getHomeList()
+			if err != nil {
+				return err
+			}
+			err = render(homes, func() error {
+				data := pterm.TableData{{"Home", "ID", "Rooms", "Devices"}}
+				for _, home := range homes {
+					count := len(home.Dids)
+					for _, room := range home.Rooms {
+						count += len(room.Dids)
+					}
+					data = append(data, []string{home.Name, home.ID, fmt.Sprint(len(home.Rooms)), fmt.Sprint(count)})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+			return err
+		},
+	}
+	homeRoomsCmd = &cobra.Command{
+		Use:   "rooms [?home]",
+		Short: "List rooms",
+		Long:  `List rooms`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			homes, err := getHomeList()
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				homes = lo.Filter(homes, func(h *miservice.HomeInfo, _ int) bool { return h.ID == args[0] || strings.EqualFold(h.Name, args[0]) })
+			}
+			type roomRow struct {
+				Home    string `json:"home"`
+				Room    string `json:"room"`
+				ID      string `json:"id"`
+				Devices int    `json:"devices"`
+			}
+			var rows []*roomRow
+			for _, home := range homes {
+				for _, room := range home.Rooms {
+					rows = append(rows, &roomRow{Home: home.Name, Room: room.Name, ID: room.ID, Devices: len(room.Dids)})
+				}
+			}
+			err = render(rows, func() error {
+				data := pterm.TableData{{"Home", "Room", "ID", "Devices"}}
+				for _, r := range rows {
+					data = append(data, []string{r.Home, r.Room, r.ID, fmt.Sprint(r.Devices)})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+			return err
+		},
+	}
+	homeDevicesCmd = &cobra.Command{
+		Use:   "devices [?room]",
+		Short: "List devices grouped by room",
+		Long:  `List devices grouped by room`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			homes, err := getHomeList()
+			if err != nil {
+				return err
+			}
+			var devices []*miservice.DeviceInfo
+			devices, err = getDeviceListFromLocal()
+			if err != nil {
+				return err
+			}
+			deviceMap := lo.KeyBy(devices, func(d *miservice.DeviceInfo) string { return d.Did })
+			var roomFilter string
+			if len(args) > 0 {
+				roomFilter = args[0]
+			}
+			deviceItem := func(did string) pterm.LeveledListItem {
+				if d, ok := deviceMap[did]; ok {
+					text := fmt.Sprintf("%s (did:%s, model:%s)", pterm.Green(d.Name), d.Did, d.Model)
+					if !d.IsOnline {
+						text = fmt.Sprintf("%s (did:%s, model:%s, offline)", pterm.Gray(d.Name), d.Did, d.Model)
+					}
+					return pterm.LeveledListItem{Level: 2, Text: text}
+				}
+				return pterm.LeveledListItem{Level: 2, Text: fmt.Sprintf("did:%s", did)}
+			}
+			if outputFormat != outputTable {
+				type roomDevice struct {
+					Home   string `json:"home"`
+					Room   string `json:"room"`
+					Did    string `json:"did"`
+					Name   string `json:"name"`
+					Model  string `json:"model"`
+					Online bool   `json:"online"`
+				}
+				var rows []*roomDevice
+				add := func(home, room, did string) {
+					row := &roomDevice{Home: home, Room: room, Did: did}
+					if d, ok := deviceMap[did]; ok {
+						row.Name, row.Model, row.Online = d.Name, d.Model, d.IsOnline
+					}
+					rows = append(rows, row)
+				}
+				for _, home := range homes {
+					for _, room := range home.Rooms {
+						if roomFilter != "" && !strings.EqualFold(room.Name, roomFilter) && room.ID != roomFilter {
+							continue
+						}
+						for _, did := range room.Dids {
+							add(home.Name, room.Name, did)
+						}
+					}
+					if roomFilter == "" {
+						for _, did := range home.Dids {
+							add(home.Name, "", did)
+						}
+					}
+				}
+				return render(rows, nil)
+			}
+			var leveledList pterm.LeveledList
+			for _, home := range homes {
+				leveledList = append(leveledList, pterm.LeveledListItem{Level: 0, Text: pterm.Cyan(home.Name)})
+				for _, room := range home.Rooms {
+					if roomFilter != "" && !strings.EqualFold(room.Name, roomFilter) && room.ID != roomFilter {
+						continue
+					}
+					leveledList = append(leveledList, pterm.LeveledListItem{Level: 1, Text: pterm.Magenta(room.Name)})
+					for _, did := range room.Dids {
+						leveledList = append(leveledList, deviceItem(did))
+					}
+				}
+				if roomFilter == "" && len(home.Dids) > 0 {
+					leveledList = append(leveledList, pterm.LeveledListItem{Level: 1, Text: pterm.Magenta("(no room)")})
+					for _, did := range home.Dids {
+						leveledList = append(leveledList, deviceItem(did))
+					}
+				}
+			}
+			root := putils.TreeFromLeveledList(leveledList)
+			root.Text = pterm.NewStyle(pterm.FgRed).Sprint("Homes")
+			err = pterm.DefaultTree.WithRoot(root).Render()
+			return err
+		},
+	}
+)
+
+func init() {
+	homeCmd.PersistentFlags().BoolVarP(&reload, "reload", "r", false, "reload home list")
+	homeCmd.AddCommand(homeListCmd)
+	homeCmd.AddCommand(homeRoomsCmd)
+	homeCmd.AddCommand(homeDevicesCmd)
+	homeCmd.Example = "  home list\n  home rooms\n  home devices Bedroom"
+}
+
+// getHomeList returns the cached homes, fetching them when missing or --reload is set
+func getHomeList() (homes []*miservice.HomeInfo, err error) {
+	if !reload && util.Exists(homesPath) {
+		var f *os.File
+		f, err = os.Open(homesPath)
+		if err != nil {
+			return
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		err = json.NewDecoder(f).Decode(&homes)
+		return
+	}
+	homes, err = ioSrv.HomeList()
+	if err != nil {
+		return
+	}
+	var f *os.File
+	f, err = util.CreatNestedFile(homesPath)
+	if err != nil {
+		return
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	err = json.NewEncoder(f).Encode(homes)
+	return
+}
+
+// deviceRooms maps each did to its room name, devices outside any room are left out
+func deviceRooms() map[string]string {
+	rooms := make(map[string]string)
+	homes, err := getHomeList()
+	if err != nil {
+		pterm.Debug.Printf("Fail to load homes: %v\n", err)
+		return rooms
+	}
+	for _, home := range homes {
+		for _, room := range home.Rooms {
+			for _, did := range room.Dids {
+				rooms[did] = room.Name
+			}
+		}
+	}
+	return rooms
+}
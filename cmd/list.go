@@ -3,8 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path"
+	"sort"
 	"strings"
 
+	"micli/internal/conf"
 	"micli/pkg/miservice"
 	"micli/pkg/util"
 
@@ -16,11 +19,14 @@ import (
 var (
 	devicesPath = "./data/devices.json"
 	reload      bool
+	onlineOnly  bool
+	modelGlob   string
 	listCmd     = &cobra.Command{
 		Use:   "list [?name=full|name_keyword]",
 		Short: "Devs List",
-		Long:  `Devs List`,
-		Run: func(cmd *cobra.Command, args []string) {
+		Long: `Devs List
+Filter with --online and --model, which accepts a glob such as 'xiaomi.light.*'.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			pterm.Debug.Println("listCmd called")
 			argLen := len(args)
 			var (
@@ -35,59 +41,99 @@ var (
 			if reload {
 				devices, err = getDeviceListFromRemote()
 				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
+					return err
 				}
 				err = writeIntoLocal(devices)
 				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
+					return err
 				}
 			} else {
 				devices, err = getDeviceListFromLocal()
 				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
+					return err
 				}
 			}
 			if arg0 != "" {
 				devices = lo.Filter(devices, func(s *miservice.DeviceInfo, index int) bool { return strings.Contains(s.Name, arg0) })
 			}
-
-			var items []pterm.BulletListItem
-			for _, device := range devices {
-				items = append(items, pterm.BulletListItem{
-					Level:     0,
-					TextStyle: pterm.NewStyle(pterm.FgGreen),
-					Text:      device.Name,
-				})
-				items = append(items, pterm.BulletListItem{
-					Level:  1,
-					Text:   fmt.Sprintf("DID: %s", device.Did),
-					Bullet: ">",
-				})
-				items = append(items, pterm.BulletListItem{
-					Level:  1,
-					Text:   fmt.Sprintf("Model: %s", device.Model),
-					Bullet: ">",
-				})
-				items = append(items, pterm.BulletListItem{
-					Level:  1,
-					Text:   fmt.Sprintf("Token: %s", device.Token),
-					Bullet: ">",
-				})
+			if onlineOnly {
+				devices = lo.Filter(devices, func(s *miservice.DeviceInfo, index int) bool { return s.IsOnline })
 			}
-			err = pterm.DefaultBulletList.WithItems(items).Render()
-			if err != nil {
-				pterm.Error.Println(err.Error())
+			if modelGlob != "" {
+				if _, err = path.Match(modelGlob, ""); err != nil {
+					return fmt.Errorf("invalid --model pattern: %w", err)
+				}
+				devices = lo.Filter(devices, func(s *miservice.DeviceInfo, index int) bool {
+					ok, _ := path.Match(modelGlob, s.Model)
+					return ok
+				})
 			}
+
+			err = render(devices, func() error { return renderDeviceList(devices) })
+			return err
 		},
 	}
 )
 
 func init() {
-	listCmd.Example = "  list Light"
+	listCmd.Example = "  list Light\n  list --online --model 'xiaomi.light.*'"
 	listCmd.Flags().BoolVarP(&reload, "reload", "r", false, "reload device list")
+	listCmd.Flags().BoolVar(&onlineOnly, "online", false, "only show online devices")
+	listCmd.Flags().StringVar(&modelGlob, "model", "", "only show models matching the glob")
+}
+
+// renderDeviceList prints devices as a bullet list
+func renderDeviceList(devices []*miservice.DeviceInfo) error {
+	var items []pterm.BulletListItem
+	for _, device := range devices {
+		nameStyle, online := pterm.NewStyle(pterm.FgGreen), "online"
+		if !device.IsOnline {
+			nameStyle, online = pterm.NewStyle(pterm.FgGray), "offline"
+		}
+		items = append(items, pterm.BulletListItem{
+			Level:     0,
+			TextStyle: nameStyle,
+			Text:      fmt.Sprintf("%s (%s)", device.Name, online),
+		})
+		items = append(items, pterm.BulletListItem{
+			Level:  1,
+			Text:   fmt.Sprintf("DID: %s", device.Did),
+			Bullet: ">",
+		})
+		items = append(items, pterm.BulletListItem{
+			Level:  1,
+			Text:   fmt.Sprintf("Model: %s", device.Model),
+			Bullet: ">",
+		})
+		items = append(items, pterm.BulletListItem{
+			Level:  1,
+			Text:   fmt.Sprintf("Token: %s", device.Token),
+			Bullet: ">",
+		})
+		rssi := ""
+		if device.RSSI != 0 {
+			rssi = fmt.Sprint(device.RSSI)
+		}
+		details := [][2]string{
+			{"IP", device.LocalIP},
+			{"MAC", device.Mac},
+			{"SSID", device.SSID},
+			{"RSSI", rssi},
+			{"Parent", device.ParentID},
+			{"Firmware", device.FwVersion},
+		}
+		for _, detail := range details {
+			if detail[1] == "" {
+				continue
+			}
+			items = append(items, pterm.BulletListItem{
+				Level:  1,
+				Text:   fmt.Sprintf("%s: %s", detail[0], detail[1]),
+				Bullet: ">",
+			})
+		}
+	}
+	return pterm.DefaultBulletList.WithItems(items).Render()
 }
 
 func getDeviceListFromRemote() (res []*miservice.DeviceInfo, err error) {
@@ -145,12 +191,28 @@ func chooseDevice() (did string, err error) {
 	if err != nil {
 		return
 	}
+	// group the choices by room, devices without a room go last
+	rooms := deviceRooms()
+	devices = append([]*miservice.DeviceInfo{}, devices...)
+	sort.SliceStable(devices, func(i, j int) bool {
+		ri, rj := rooms[devices[i].Did], rooms[devices[j].Did]
+		if ri == "" || rj == "" {
+			return ri != "" && rj == ""
+		}
+		return ri < rj
+	})
 	choices := make([]string, len(devices))
 	for i, device := range devices {
 		choice := fmt.Sprintf("%s - %s", device.Name, device.Did)
+		if room, ok := rooms[device.Did]; ok {
+			choice = fmt.Sprintf("%s/%s - %s", room, device.Name, device.Did)
+		}
 		deviceMap[choice] = device.Did
 		choices[i] = choice
 	}
+	if err = conf.RequirePrompt("no device given, pass one or set MI_DID"); err != nil {
+		return
+	}
 	choice, _ := pterm.DefaultInteractiveSelect.
 		WithDefaultText("Please select a device").
 		WithOptions(choices).
@@ -159,3 +221,38 @@ func chooseDevice() (did string, err error) {
 	did = deviceMap[choice]
 	return
 }
+
+// resolveDevice finds a device in the cache by did, name or a room/name selector such as "Bedroom/Lamp".
+// An unknown numeric did is returned as is so devices missing from the cache still work.
+func resolveDevice(selector string) (device *miservice.DeviceInfo, err error) {
+	var devices []*miservice.DeviceInfo
+	devices, err = getDeviceListFromLocal()
+	if err != nil {
+		return
+	}
+	if util.IsDigit(selector) {
+		if d, ok := lo.Find(devices, func(d *miservice.DeviceInfo) bool { return d.Did == selector }); ok {
+			return d, nil
+		}
+		return &miservice.DeviceInfo{Did: selector}, nil
+	}
+	var matches []*miservice.DeviceInfo
+	if room, name, ok := strings.Cut(selector, "/"); ok {
+		rooms := deviceRooms()
+		matches = lo.Filter(devices, func(d *miservice.DeviceInfo, _ int) bool {
+			return d.Name == name && strings.EqualFold(rooms[d.Did], room)
+		})
+	}
+	if len(matches) == 0 {
+		matches = lo.Filter(devices, func(d *miservice.DeviceInfo, _ int) bool { return d.Name == selector })
+	}
+	switch len(matches) {
+	case 0:
+		err = fmt.Errorf("device %q not found", selector)
+	case 1:
+		device = matches[0]
+	default:
+		err = fmt.Errorf("device name %q is ambiguous, use room/name or the did", selector)
+	}
+	return
+}
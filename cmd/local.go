@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"micli/internal/conf"
+	"micli/pkg/miio"
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	local          bool
+	localIP        string
+	localTransport *miservice.LocalTransport
+)
+
+// addLocalFlags registers the --local/--ip flags on commands that can talk to devices over LAN
+func addLocalFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&local, "local", false, "talk to the device over LAN (miIO) instead of the cloud")
+	cmd.Flags().StringVar(&localIP, "ip", "", "device LAN address for --local, defaults to the cached localip")
+}
+
+// initRoutes applies the [route] section of the config, e.g. `12345 = local`
+func initRoutes() {
+	localTransport = miservice.NewLocalTransport(lookupLocalDevice)
+	for _, key := range conf.Cfg.Section("route").Keys() {
+		switch strings.ToLower(key.String()) {
+		case miservice.TransportLocal:
+			ioSrv.SetRoute(key.Name(), localTransport)
+		case miservice.TransportCloud:
+			ioSrv.SetRoute(key.Name(), nil)
+		default:
+			pterm.Warning.Printf("Unknown route %q for device %s, using cloud\n", key.String(), key.Name())
+		}
+	}
+}
+
+// useLocal routes did through the LAN transport when --local is set
+func useLocal(did string) {
+	if local {
+		ioSrv.SetRoute(did, localTransport)
+	}
+}
+
+// lookupLocalDevice finds the LAN address and token of a device in the device cache
+func lookupLocalDevice(did string) (ip, token string, err error) {
+	var devices []*miservice.DeviceInfo
+	devices, err = getDeviceListFromLocal()
+	if err != nil {
+		return
+	}
+	device, ok := lo.Find(devices, func(d *miservice.DeviceInfo) bool { return d.Did == did })
+	if !ok {
+		err = fmt.Errorf("device %s not found in device cache, try `list -r`", did)
+		return
+	}
+	ip = localIP
+	if ip == "" {
+		discovered, _ := getDiscoveryFromLocal()
+		if d, ok := lo.Find(discovered, func(d *DiscoveredDevice) bool { return d.Did == did && d.IP != "" }); ok {
+			ip = d.IP
+		}
+	}
+	if ip == "" {
+		ip = device.LocalIP
+	}
+	if ip == "" {
+		err = fmt.Errorf("no LAN address known for device %s, pass --ip or run `discover`", did)
+		return
+	}
+	return ip, device.Token, nil
+}
+
+// localClient builds a LAN miIO client for the device, using the token and address from the device cache
+func localClient(did string) (*miio.Client, error) {
+	ip, token, err := lookupLocalDevice(did)
+	if err != nil {
+		return nil, err
+	}
+	return miio.New(ip, token)
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"errors"
+
+	"micli/pkg/miio"
 	"micli/pkg/util"
 
 	"github.com/spf13/cobra"
@@ -9,24 +12,45 @@ import (
 var miioRawCmd = &cobra.Command{
 	Use:   "miio_raw /<uri> <data>",
 	Short: "Call MiIO Raw Request",
-	Long:  `Call MiIO Raw Request`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Call MiIO Raw Request.
+With --local the first argument is a miIO method sent directly to the device, e.g. get_prop.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			res interface{}
 			err error
 		)
 		uri := args[0]
-		if util.IsJSON(args[1]) {
+		if local {
+			var params interface{}
+			if len(args) > 1 {
+				if err = json.Unmarshal([]byte(args[1]), &params); err != nil {
+					return err
+				}
+			}
+			if did == "" {
+				return errors.New("no did found, pass --did")
+			}
+			var client *miio.Client
+			client, err = localClient(did)
+			if err == nil {
+				res, err = client.Send(uri, params)
+			}
+			return handleResult(res, err)
+		}
+		if len(args) > 1 && util.IsJSON(args[1]) {
 			var params map[string]interface{}
 			if err = json.Unmarshal([]byte(args[1]), &params); err != nil {
-				return
+				return err
 			}
 			res, err = ioSrv.Request(uri, params)
 		}
-		handleResult(res, err)
+		return handleResult(res, err)
 	},
 }
 
 func init() {
-	miioRawCmd.Example = "  miio_raw /home/device_list '{\"getVirtualModel\":false,\"getHuamiDevices\":1}'\n  miio_raw /<uri> <data>"
+	miioRawCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID, used with --local")
+	addLocalFlags(miioRawCmd)
+	miioRawCmd.Example = "  miio_raw /home/device_list '{\"getVirtualModel\":false,\"getHuamiDevices\":1}'\n  miio_raw /<uri> <data>\n  miio_raw --local -d 636889807 get_prop '[\"power\"]'"
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"micli/internal/conf"
 	"micli/pkg/miservice"
 
 	"github.com/pterm/pterm"
@@ -54,6 +55,9 @@ func selectMinaDevice(srv *miservice.MinaService, targetDeviceId string, returnD
 		deviceMap[choice] = d
 		choices[i] = choice
 	}
+	if err = conf.RequirePrompt("no XiaoAi device given, pass --device"); err != nil {
+		return
+	}
 	choice, _ := pterm.DefaultInteractiveSelect.
 		WithDefaultText("Please select a device").
 		WithOptions(choices).
@@ -15,7 +15,7 @@ var minaListCmd = &cobra.Command{
 	Use:   "list <?keyword>",
 	Short: "List devices",
 	Long:  `List devices`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			keyword string
 			err     error
@@ -24,9 +24,7 @@ var minaListCmd = &cobra.Command{
 			keyword = args[0]
 		}
 		_, err = list(minaSrv, keyword)
-		if err != nil {
-			pterm.Error.Println(err.Error())
-		}
+		return err
 	},
 }
 
@@ -40,6 +38,13 @@ func list(srv *miservice.MinaService, keyword string) (res interface{}, err erro
 	if keyword != "" {
 		devices = lo.Filter(devices, func(s *miservice.DeviceData, index int) bool { return strings.Contains(s.Name, keyword) })
 	}
+	err = render(devices, func() error {
+		return renderMinaDevices(devices)
+	})
+	return
+}
+
+func renderMinaDevices(devices []*miservice.DeviceData) error {
 	var items []pterm.BulletListItem
 	for i, device := range devices {
 		items = append(items, pterm.BulletListItem{
@@ -73,8 +78,7 @@ func list(srv *miservice.MinaService, keyword string) (res interface{}, err erro
 			Bullet: ">",
 		})
 	}
-	err = pterm.DefaultBulletList.WithItems(items).Render()
-	return
+	return pterm.DefaultBulletList.WithItems(items).Render()
 }
 
 func init() {
@@ -19,16 +19,22 @@ var minaPlayerCmd = &cobra.Command{
 	Use:   "player <play|pause|volume|status> <?arg2>",
 	Short: "Player",
 	Long:  `Player`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			res interface{}
 			err error
 		)
 		res, err = operatePlayer(minaSrv, args)
-		handleResult(res, err)
+		return handleResult(res, err)
 	},
 }
 
+type playerStatus struct {
+	DeviceID string `json:"device_id"`
+	Status   int    `json:"status"`
+	Volume   int    `json:"volume"`
+}
+
 // operatePlayer 播放器操作
 func operatePlayer(srv *miservice.MinaService, args []string) (res interface{}, err error) {
 	deviceId := minaDeviceID
@@ -59,23 +65,26 @@ func operatePlayer(srv *miservice.MinaService, args []string) (res interface{},
 			if err != nil {
 				return
 			}
-			var items []pterm.BulletListItem
-			items = append(items, pterm.BulletListItem{
-				Level:     0,
-				TextStyle: pterm.NewStyle(pterm.FgGreen),
-				Text:      deviceId,
-			})
-			items = append(items, pterm.BulletListItem{
-				Level:  1,
-				Text:   fmt.Sprintf("Status: %s", pterm.Green(dataInfo.Status)),
-				Bullet: ">",
-			})
-			items = append(items, pterm.BulletListItem{
-				Level:  1,
-				Text:   fmt.Sprintf("Volume: %s", pterm.Green(dataInfo.Volume)),
-				Bullet: ">",
+			status := &playerStatus{DeviceID: deviceId, Status: dataInfo.Status, Volume: dataInfo.Volume}
+			err = render(status, func() error {
+				var items []pterm.BulletListItem
+				items = append(items, pterm.BulletListItem{
+					Level:     0,
+					TextStyle: pterm.NewStyle(pterm.FgGreen),
+					Text:      deviceId,
+				})
+				items = append(items, pterm.BulletListItem{
+					Level:  1,
+					Text:   fmt.Sprintf("Status: %s", pterm.Green(dataInfo.Status)),
+					Bullet: ">",
+				})
+				items = append(items, pterm.BulletListItem{
+					Level:  1,
+					Text:   fmt.Sprintf("Volume: %s", pterm.Green(dataInfo.Volume)),
+					Bullet: ">",
+				})
+				return pterm.DefaultBulletList.WithItems(items).Render()
 			})
-			err = pterm.DefaultBulletList.WithItems(items).Render()
 		case "play":
 			if len(args) > 1 {
 				audioUrl := args[1]
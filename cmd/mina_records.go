@@ -16,13 +16,13 @@ var minaRecordsCmd = &cobra.Command{
 	Use:   "records <?limit>",
 	Short: "Get records",
 	Long:  `Get records`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			res interface{}
 			err error
 		)
 		res, err = askRecords(minaSrv, args)
-		handleResult(res, err)
+		return handleResult(res, err)
 	},
 }
 
@@ -54,39 +54,56 @@ func askRecords(srv *miservice.MinaService, args []string) (res interface{}, err
 	if err != nil {
 		return
 	}
+	rows := make([]*askRecordRow, 0, len(record.Records))
+	for _, _record := range record.Records {
+		row := &askRecordRow{
+			Time:  time.UnixMilli(_record.Time).Format("2006-01-02 15:04:05"),
+			Query: _record.Query,
+		}
+		if len(_record.Answers) > 0 {
+			row.Answer = _record.Answers[0].Tts.Text
+		}
+		rows = append(rows, row)
+	}
+	err = render(rows, func() error {
+		return renderAskRecords(device.Name, rows)
+	})
+	return
+}
+
+type askRecordRow struct {
+	Time   string `json:"time"`
+	Query  string `json:"query"`
+	Answer string `json:"answer"`
+}
+
+func renderAskRecords(name string, rows []*askRecordRow) error {
 	var items []pterm.BulletListItem
 	items = append(items, pterm.BulletListItem{
 		Level:     0,
 		TextStyle: pterm.NewStyle(pterm.FgGreen),
-		Text:      device.Name,
+		Text:      name,
 	})
 
-	for _, _record := range record.Records {
+	for _, row := range rows {
 		items = append(items, pterm.BulletListItem{
 			Level:     1,
-			Text:      fmt.Sprintf("Time: %s", time.UnixMilli(_record.Time).Format("2006-01-02 15:04:05")),
+			Text:      fmt.Sprintf("Time: %s", row.Time),
 			Bullet:    "-",
 			TextStyle: pterm.NewStyle(pterm.FgCyan),
 		})
 		items = append(items, pterm.BulletListItem{
 			Level:  2,
-			Text:   fmt.Sprintf("Q: %s", _record.Query),
+			Text:   fmt.Sprintf("Q: %s", row.Query),
 			Bullet: ">",
 		})
-		var a string
-		if len(_record.Answers) > 0 {
-			a = _record.Answers[0].Tts.Text
-		}
-
 		items = append(items, pterm.BulletListItem{
 			Level:  2,
-			Text:   fmt.Sprintf("A: %s", a),
+			Text:   fmt.Sprintf("A: %s", row.Answer),
 			Bullet: ">",
 		})
 	}
-	err = pterm.DefaultBulletList.WithItems(items).Render()
-
-	return
+	return pterm.DefaultBulletList.WithItems(items).Render()
 }
 
 func init() {
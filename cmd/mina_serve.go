@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -12,6 +13,7 @@ import (
 
 	"micli/internal"
 	"micli/internal/conf"
+	"micli/internal/handlers"
 	"micli/pkg/jarvis"
 	"micli/pkg/miservice"
 	"micli/pkg/tts"
@@ -53,7 +55,7 @@ var (
 		Use:   "serve",
 		Short: "Hack xiaoai Project(WIP)",
 		Long:  `Hack xiaoai Project`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			s := NewServe()
 			sigs := make(chan os.Signal, 1)
 			signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, os.Interrupt)
@@ -61,9 +63,7 @@ var (
 				<-sigs
 				s.Exit()
 			}()
-			if err := s.Run(); err != nil {
-				pterm.Fatal.Println(err)
-			}
+			return s.Run()
 		},
 	}
 )
@@ -108,6 +108,10 @@ type Command struct {
 			UseEdgeTTS   bool   `json:"useEdgeTTS"`
 			EdgeTTSVoice string `json:"edgeTTSVoice"`
 		} `json:"tts,omitempty"`
+		Scene struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"scene,omitempty"`
 	} `json:"step,omitempty"`
 }
 
@@ -310,6 +314,18 @@ func (s *Serve) Call(text string, silent bool) (err error) {
 	return
 }
 
+// runScene 执行米家场景，优先使用场景ID
+func (s *Serve) runScene(id, name string) error {
+	if id == "" {
+		scene, err := findScene(name)
+		if err != nil {
+			return err
+		}
+		id = scene.ID
+	}
+	return s.miioSrv.RunScene(id)
+}
+
 func (s *Serve) waitForTTSDone() {
 	for {
 		isPlaying, _ := s.isPlaying()
@@ -341,9 +357,18 @@ func (s *Serve) Run() error {
 	if deviceId == "" {
 		deviceId = conf.Cfg.Section("mina").Key("DID").MustString("")
 	}
+	// a login QR code is also shown on /login of the app
+	ms.SetQRHandler(func(loginURL, imageURL string) error {
+		_ = webQR(loginURL, imageURL)
+		return miservice.TerminalQR(loginURL, imageURL)
+	})
 	var device *miservice.DeviceData
 	device, err = chooseMinaDeviceDetail(s.minaSrv, deviceId)
+	handlers.SetLoginQR(nil)
 	s.device = device
+	// from here on nobody is watching for a QR code, tokens are renewed in the background
+	ms.SetInteractive(false)
+	ms.StartRefresh(context.Background(), tokenRefreshInterval, tokenRefreshAge())
 	s.inChat = false
 	pterm.Info.Println("Start Listen Device: " + device.Name)
 	s.LastTimestamp = time.Now().UnixMilli()
@@ -522,6 +547,12 @@ func (s *Serve) Run() error {
 						if err != nil {
 							pterm.Error.Println(err.Error())
 						}
+					case "scene":
+						pterm.Debug.Println("Start execute scene")
+						err = s.runScene(step.Scene.ID, step.Scene.Name)
+						if err != nil {
+							pterm.Error.Println(err.Error())
+						}
 					}
 				}
 			}
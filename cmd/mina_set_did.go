@@ -13,7 +13,7 @@ var setMinaDidCmd = &cobra.Command{
 	Use:   "set_did",
 	Short: "Set the default mina device id",
 	Long:  `Set the default mina device id`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			err error
 			res interface{}
@@ -22,19 +22,18 @@ var setMinaDidCmd = &cobra.Command{
 		if did == "" || reset {
 			did, err = chooseMinaDevice(minaSrv)
 			if err != nil {
-				pterm.Error.Println(err.Error())
-				return
+				return err
 			}
 		} else {
 			res = fmt.Sprintf("The default mina device id already set : %s", did)
 			pterm.Success.Println(res)
-			return
+			return nil
 		}
 
 		if did != "" {
 			err = conf.SetDefaultMinaDid(did)
 		}
-		handleResult(res, err)
+		return handleResult(res, err)
 	},
 }
 
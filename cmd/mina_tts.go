@@ -12,7 +12,7 @@ var minaTtsCmd = &cobra.Command{
 	Use:   "tts <text>",
 	Short: "Text to speech",
 	Long:  `Text to speech`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			res interface{}
 			err error
@@ -22,7 +22,7 @@ var minaTtsCmd = &cobra.Command{
 		} else {
 			err = errors.New("tts message is empty")
 		}
-		handleResult(res, err)
+		return handleResult(res, err)
 	},
 }
 
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"errors"
+
 	"micli/pkg/util"
 
 	"github.com/spf13/cobra"
@@ -10,23 +12,51 @@ var miotRawCmd = &cobra.Command{
 	Use:   "miot_raw <cmd> <params>",
 	Short: "Call MIoT Raw Request",
 	Long:  `Call MIoT Raw Request`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			res interface{}
 			err error
 		)
 		uri := args[0]
 		if util.IsJSON(args[1]) {
-			var params []map[string]interface{}
+			var params interface{}
 			if err = json.Unmarshal([]byte(args[1]), &params); err != nil {
-				return
+				return err
+			}
+			target := did
+			if target == "" {
+				target = paramsDid(params)
+			}
+			if target != "" {
+				useLocal(target)
+				res, err = ioSrv.MiotDeviceRequest(target, uri, params)
+			} else if local {
+				err = errors.New("no did found, pass --did")
+			} else {
+				res, err = ioSrv.MiotRequest(uri, params)
 			}
-			res, err = ioSrv.MiotRequest(uri, params)
 		}
-		handleResult(res, err)
+		return handleResult(res, err)
 	},
 }
 
+// paramsDid extracts the did from raw miot params, either a map or a list of maps
+func paramsDid(params interface{}) string {
+	switch v := params.(type) {
+	case map[string]interface{}:
+		s, _ := v["did"].(string)
+		return s
+	case []interface{}:
+		if len(v) > 0 {
+			return paramsDid(v[0])
+		}
+	}
+	return ""
+}
+
 func init() {
-	miotRawCmd.Example = "  miot_raw prop/get '[{\"did\":\"636889807\",\"siid\":2,\"piid\":1}]'\n  miot_raw <prop/get|prop/set|action> <params>"
+	miotRawCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID, used with --local")
+	addLocalFlags(miotRawCmd)
+	miotRawCmd.Example = "  miot_raw prop/get '[{\"did\":\"636889807\",\"siid\":2,\"piid\":1}]'\n  miot_raw <prop/get|prop/set|action> <params>\n  miot_raw --local action '{\"did\":\"636889807\",\"siid\":5,\"aiid\":1,\"in\":[\"hi\"]}'"
 }
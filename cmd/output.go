@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputPlain = "plain"
+)
+
+var outputFormat string
+
+// initOutput validates --output and, for machine readable formats, moves all
+// pterm messages to stderr so stdout only carries the result.
+func initOutput() {
+	switch outputFormat {
+	case outputTable:
+		return
+	case outputJSON, outputYAML, outputPlain:
+	default:
+		pterm.Error.Printf("Unknown output format %q, use table|json|yaml|plain\n", outputFormat)
+		os.Exit(exitUsage)
+	}
+	pterm.DisableColor()
+	for _, p := range []*pterm.PrefixPrinter{&pterm.Info, &pterm.Success, &pterm.Warning, &pterm.Error, &pterm.Debug, &pterm.Fatal, &pterm.Description} {
+		p.Writer = os.Stderr
+	}
+}
+
+// render prints data in the selected --output format, table uses the command's own pretty printer
+func render(data interface{}, pretty func() error) error {
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case outputYAML:
+		// go through JSON so yaml keys match the json tags
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		var v interface{}
+		if err = json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	case outputPlain:
+		return printPlain(os.Stdout, data)
+	default:
+		return pretty()
+	}
+}
+
+// printPlain prints lists one item per line with tab separated fields, and
+// single objects as "key<TAB>value" lines. Nested values are compact JSON.
+func printPlain(w io.Writer, data interface{}) error {
+	v := indirect(reflect.ValueOf(data))
+	var err error
+	switch v.Kind() {
+	case reflect.Invalid:
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len() && err == nil; i++ {
+			item := indirect(v.Index(i))
+			var row []string
+			if item.Kind() == reflect.Struct || item.Kind() == reflect.Map {
+				for _, f := range plainFields(item) {
+					row = append(row, f[1])
+				}
+			} else {
+				row = []string{plainValue(item)}
+			}
+			_, err = fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+	case reflect.Struct, reflect.Map:
+		for _, f := range plainFields(v) {
+			if _, err = fmt.Fprintf(w, "%s\t%s\n", f[0], f[1]); err != nil {
+				break
+			}
+		}
+	default:
+		_, err = fmt.Fprintln(w, plainValue(v))
+	}
+	return err
+}
+
+// plainFields returns the [name, value] pairs of a struct in field order, or of a map sorted by key
+func plainFields(v reflect.Value) [][2]string {
+	var fields [][2]string
+	if v.Kind() == reflect.Map {
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			fields = append(fields, [2]string{fmt.Sprint(k), plainValue(v.MapIndex(k))})
+		}
+		return fields
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		// embedded structs are flattened like encoding/json does
+		if fv := indirect(v.Field(i)); f.Anonymous && fv.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			fields = append(fields, plainFields(fv)...)
+			continue
+		}
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields = append(fields, [2]string{name, plainValue(v.Field(i))})
+	}
+	return fields
+}
+
+func plainValue(v reflect.Value) string {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return ""
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"micli/internal/conf"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var regions = []string{"cn", "de", "us", "i2", "ru", "sg", "tw"}
+
+// profileInfo is one account listed by `profile list`
+type profileInfo struct {
+	Name   string `json:"name"`
+	User   string `json:"user"`
+	Region string `json:"region"`
+	Active bool   `json:"active"`
+}
+
+var (
+	profileRegion string
+	profileCmd    = &cobra.Command{
+		Use:   "profile",
+		Short: "Manage account profiles",
+		Long: `Manage account profiles.
+[account] is the "default" profile, more accounts live in [profile.<name>]
+sections, each with its own token and data/profiles/<name> directory. Pick one per command
+with --profile or for every command with "profile use".`,
+		Annotations: map[string]string{annotationNoAccount: ""},
+	}
+	profileListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Long:  `List profiles`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			active := conf.Profile
+			if active == "" {
+				active = conf.DefaultProfile
+			}
+			var list []*profileInfo
+			for _, name := range append([]string{conf.DefaultProfile}, conf.Profiles()...) {
+				s := conf.ProfileSection(name)
+				list = append(list, &profileInfo{
+					Name:   name,
+					User:   s.Key("MI_USER").String(),
+					Region: s.Key("REGION").String(),
+					Active: name == active,
+				})
+			}
+			return render(list, func() error {
+				data := pterm.TableData{{"", "Name", "User", "Region"}}
+				for _, p := range list {
+					mark := ""
+					if p.Active {
+						mark = pterm.Green("*")
+					}
+					data = append(data, []string{mark, p.Name, p.User, p.Region})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+		},
+	}
+	profileAddCmd = &cobra.Command{
+		Use:   "add <name> <user>",
+		Short: "Add a profile",
+		Long:  `Add a profile, the password is asked for the first time the profile is used`,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(regions, profileRegion) {
+				return fmt.Errorf("unknown region %q, use one of %v", profileRegion, regions)
+			}
+			if err := conf.AddProfile(args[0], args[1], profileRegion); err != nil {
+				return err
+			}
+			pterm.Success.Printf("Profile %s added, use it with --profile %s or `profile use %s`\n", args[0], args[0], args[0])
+			return nil
+		},
+	}
+	profileUseCmd = &cobra.Command{
+		Use:   "use <name>",
+		Short: "Set the profile used by default",
+		Long:  `Set the profile used when --profile is not given, "default" is the [account] section`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := conf.UseProfile(args[0]); err != nil {
+				return err
+			}
+			pterm.Success.Printf("Using profile %s\n", args[0])
+			return nil
+		},
+	}
+	profileRemoveCmd = &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a profile",
+		Long:  `Remove a profile with its token, its "keyring:" password and its data/profiles/<name> directory`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if name == conf.DefaultProfile {
+				return fmt.Errorf("the default profile can not be removed")
+			}
+			if conf.ProfileSection(name) == nil {
+				return fmt.Errorf("profile %q not found", name)
+			}
+			// the token store of a profile follows conf.Profile
+			active := conf.Profile
+			conf.Profile = name
+			tokenStore, err := newTokenStore()
+			conf.Profile = active
+			if err != nil {
+				return err
+			}
+			if err = tokenStore.SaveToken(nil); err != nil {
+				pterm.Warning.Printf("Fail to remove the token of %s: %v\n", name, err)
+			}
+			if err = os.RemoveAll(filepath.Join("data", "profiles", name)); err != nil {
+				return err
+			}
+			if err = conf.RemoveProfile(name); err != nil {
+				return err
+			}
+			pterm.Success.Printf("Profile %s removed\n", name)
+			return nil
+		},
+	}
+)
+
+func init() {
+	profileAddCmd.Flags().StringVar(&profileRegion, "region", "cn", "account region: cn|de|us|i2|ru|sg|tw")
+	profileCmd.AddCommand(profileListCmd, profileAddCmd, profileUseCmd, profileRemoveCmd)
+	profileCmd.Example = "  profile add parents someone@example.com --region de\n  profile use parents\n  list --profile default\n  profile list\n  profile remove parents"
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"micli/internal/conf"
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var propsDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "MIoT Properties Dump",
+	Long:  `Read every readable property of a device, in batches, and print them with their unit and value-list description`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		if did == "" {
+			did = conf.Account().Key("MI_DID").MustString("")
+			if did == "" {
+				did, err = chooseDevice()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		var device *miservice.DeviceInfo
+		device, err = resolveDevice(did)
+		if err != nil {
+			return err
+		}
+		did = device.Did
+		var specs *miservice.MiotSpecInstancesData
+		specs, err = ioSrv.MiotSpec(device.Model)
+		if err != nil {
+			return err
+		}
+		var (
+			props [][]interface{}
+			rows  []*dumpRow
+			refs  []*miservice.MiotSpecProperty
+		)
+		for _, s := range specs.Services {
+			for _, p := range s.Properties {
+				if !p.Readable() {
+					continue
+				}
+				props = append(props, []interface{}{s.Iid, p.Iid})
+				refs = append(refs, p)
+				rows = append(rows, &dumpRow{Siid: s.Iid, Piid: p.Iid, Service: s.Description, Property: p.Description, Unit: p.Unit})
+			}
+		}
+		if len(props) == 0 {
+			return errors.New("no readable property found")
+		}
+		useLocal(did)
+		var data []*miservice.PropResult
+		data, err = ioSrv.MiotGetPropsBatched(did, props)
+		if err != nil {
+			return err
+		}
+		for i, r := range data {
+			rows[i].Value = refs[i].Decode(r.Value)
+			rows[i].Text = refs[i].ValueText(rows[i].Value)
+			rows[i].Code, rows[i].Reason = r.Code, r.Reason
+		}
+		return render(rows, func() error {
+			pterm.Info.Printf("%s (%s, %s)\n", device.Name, did, device.Model)
+			table := pterm.TableData{{"Id", "Service", "Property", "Value", "Unit", "Description"}}
+			for _, r := range rows {
+				value := fmt.Sprint(r.Value)
+				if r.Code != 0 {
+					value = pterm.Red(fmt.Sprintf("%s (%d)", r.Reason, r.Code))
+				}
+				unit := r.Unit
+				if unit == "none" {
+					unit = ""
+				}
+				table = append(table, []string{fmt.Sprintf("%d-%d", r.Siid, r.Piid), r.Service, r.Property, value, unit, r.Text})
+			}
+			return pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		})
+	},
+}
+
+// dumpRow is one property read by `dump`
+type dumpRow struct {
+	Siid     int         `json:"siid"`
+	Piid     int         `json:"piid"`
+	Service  string      `json:"service"`
+	Property string      `json:"property"`
+	Value    interface{} `json:"value"`
+	Unit     string      `json:"unit,omitempty"`
+	Text     string      `json:"text,omitempty"`
+	Code     int         `json:"code"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+func init() {
+	propsDumpCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID, name or room/name")
+	addLocalFlags(propsDumpCmd)
+	propsDumpCmd.Example = "  dump\n  dump -d Bedroom/Lamp\n  dump -o json"
+}
@@ -1,166 +1,153 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 
 	"micli/internal/conf"
 	"micli/pkg/miservice"
-	"micli/pkg/util"
 
 	"github.com/pterm/pterm"
-	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
 var propsGetCmd = &cobra.Command{
-	Use:   "get <siid[-piid]>[,...]",
+	Use:   "get <siid[-piid]|service.property>[,...]",
 	Short: "MIoT Properties Get",
 	Long:  `MIoT Properties Get`,
-	Run: func(cmd *cobra.Command, args []string) {
-		var (
-			res         interface{}
-			err         error
-			deviceModel string
-		)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		devices, err := selectDevices()
+		if err != nil {
+			return err
+		}
+		if devices != nil {
+			return runBatch(devices, func(device *miservice.DeviceInfo) (interface{}, string, error) {
+				values, _, err := readProps(device, args)
+				summary := make([]string, 0, len(values))
+				for _, v := range values {
+					if v.Code == 0 {
+						summary = append(summary, fmt.Sprintf("%s=%v", v.Property, v.Value))
+					} else {
+						summary = append(summary, fmt.Sprintf("%s: %s", v.Property, v.Reason))
+					}
+				}
+				return values, strings.Join(summary, ", "), err
+			})
+		}
 		if did == "" {
-			did = conf.Cfg.Section("account").Key("MI_DID").MustString("")
+			did = conf.Account().Key("MI_DID").MustString("")
 			if did == "" {
 				did, err = chooseDevice()
 				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
-				}
-			}
-		}
-		var devices []*miservice.DeviceInfo
-		if !util.IsDigit(did) {
-			devices, err = getDeviceListFromLocal() // Implement this method for the IOService
-			if err != nil {
-				pterm.Error.Println(err.Error())
-				return
-			}
-			if len(devices) == 0 {
-				err = fmt.Errorf("no device found")
-				pterm.Error.Println(err.Error())
-				return
-			}
-			for _, device := range devices {
-				if device.Name == did {
-					did = device.Did
-					deviceModel = device.Model
-					break
+					return err
 				}
 			}
-		} else {
-			devices, err = getDeviceListFromLocal() // Implement this method for the IOService
-			if err != nil {
-				pterm.Error.Println(err.Error())
-				return
-			}
-			device, _ := lo.Find(devices, func(d *miservice.DeviceInfo) bool { return d.Did == did })
-			if device != nil {
-				deviceModel = device.Model
-			}
 		}
-		var specs *miservice.MiotSpecInstancesData
-		specs, err = ioSrv.MiotSpec(deviceModel)
+		var device *miservice.DeviceInfo
+		device, err = resolveDevice(did)
 		if err != nil {
-			pterm.Error.Println(err.Error())
-			return
+			return err
 		}
-		if len(specs.Services) == 0 {
-			err = fmt.Errorf("no service found")
-			pterm.Error.Println(err.Error())
-			return
+		values, title, err := readProps(device, args)
+		// when every read failed the results still carry the reasons
+		if values == nil {
+			return err
 		}
-
-		miot := true
-		var props [][]interface{}
-
-		var _args []string
-		if len(args) > 0 {
-			_args = strings.Split(args[0], ",")
-		}
-		title := specs.Description
-		var descs [][]interface{}
-		for _, item := range _args {
-			siid, iid := util.TwinsSplit(item, "-", "1")
-			var prop []interface{}
-			var desc []interface{}
-			if util.IsDigit(siid) && util.IsDigit(iid) {
-				s, _ := strconv.Atoi(siid)
-				_service, _ := lo.Find(specs.Services, func(srv *miservice.MiotSpecService) bool { return srv.Iid == s })
-				if _service == nil {
-					err = fmt.Errorf("service not found")
-					pterm.Error.Println(err.Error())
-					return
-				}
-				i, _ := strconv.Atoi(iid)
-				_prop, _ := lo.Find(_service.Properties, func(pr *miservice.MiotSpecProperty) bool { return pr.Iid == i })
-				if _prop == nil {
-					err = fmt.Errorf("property not found")
-					pterm.Error.Println(err.Error())
-					return
+		if renderErr := render(values, func() error {
+			var items []pterm.BulletListItem
+			items = append(items, pterm.BulletListItem{
+				Level:     0,
+				TextStyle: pterm.NewStyle(pterm.FgRed),
+				Text:      title,
+			})
+			for _, v := range values {
+				items = append(items, pterm.BulletListItem{
+					Level:     1,
+					TextStyle: pterm.NewStyle(pterm.FgCyan),
+					Text:      fmt.Sprintf("Service: %s", pterm.Cyan(v.Service)),
+				})
+				items = append(items, pterm.BulletListItem{
+					Level:  2,
+					Text:   fmt.Sprintf("Prop: %s", pterm.Green(v.Property)),
+					Bullet: "-",
+				})
+				value := fmt.Sprintf("Value: %v", pterm.Green(v.Value))
+				if v.Code != 0 {
+					value = fmt.Sprintf("Error: %s", pterm.Red(fmt.Sprintf("%s (%d)", v.Reason, v.Code)))
 				}
-				prop = []interface{}{s, i}
-				desc = []interface{}{_service.Description, _prop.Description}
-			} else {
-				prop = []interface{}{item}
-				miot = false
+				items = append(items, pterm.BulletListItem{
+					Level:  2,
+					Text:   value,
+					Bullet: "-",
+				})
 			}
-			props = append(props, prop)
-			descs = append(descs, desc)
+			return pterm.DefaultBulletList.WithItems(items).Render()
+		}); renderErr != nil {
+			return renderErr
 		}
-		var data []interface{}
-		if miot {
-			data, err = ioSrv.MiotGetProps(did, props)
-			if err != nil {
-				pterm.Error.Println(err.Error())
-				return
-			}
-		} else {
-			/*var _props []string
-			for _, prop := range props {
-				_props = append(_props, prop[0].(string))
-			}
-			res, err = ioSrv.HomeGetProps(did, _props)*/
-			pterm.Error.Println("device not support miot")
-			return
-		}
-
-		var items []pterm.BulletListItem
-		items = append(items, pterm.BulletListItem{
-			Level:     0,
-			TextStyle: pterm.NewStyle(pterm.FgRed),
-			Text:      title,
-		})
-		for i, item := range data {
-			desc := descs[i]
-			items = append(items, pterm.BulletListItem{
-				Level:     1,
-				TextStyle: pterm.NewStyle(pterm.FgCyan),
-				Text:      fmt.Sprintf("Service: %s", pterm.Cyan(desc[0])),
-			})
-			items = append(items, pterm.BulletListItem{
-				Level:  2,
-				Text:   fmt.Sprintf("Prop: %s", pterm.Green(desc[1])),
-				Bullet: "-",
-			})
-			items = append(items, pterm.BulletListItem{
-				Level: 2,
+		return err
+	},
+}
 
-				Text:   fmt.Sprintf("Value: %v", pterm.Green(item)),
-				Bullet: "-",
-			})
+// readProps reads the properties named by args from a device, it returns the
+// values and the spec description of the device
+func readProps(device *miservice.DeviceInfo, args []string) ([]*propValue, string, error) {
+	specs, err := ioSrv.MiotSpec(device.Model)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(specs.Services) == 0 {
+		return nil, "", errors.New("no service found")
+	}
+	var (
+		props [][]interface{}
+		descs [][]interface{}
+	)
+	for _, item := range splitArgs(args) {
+		_service, _prop, err := specs.ResolveProperty(item)
+		if err != nil {
+			return nil, "", err
 		}
-		err = pterm.DefaultBulletList.WithItems(items).Render()
-		handleResult(res, err)
-	},
+		props = append(props, []interface{}{_service.Iid, _prop.Iid})
+		descs = append(descs, []interface{}{_service.Description, _prop.Description})
+	}
+	useLocal(device.Did)
+	data, err := ioSrv.MiotGetProps(device.Did, props)
+	if data == nil {
+		return nil, specs.Description, err
+	}
+	values := make([]*propValue, len(data))
+	for i, item := range data {
+		values[i] = &propValue{
+			Did:      device.Did,
+			Siid:     props[i][0].(int),
+			Piid:     props[i][1].(int),
+			Service:  fmt.Sprint(descs[i][0]),
+			Property: fmt.Sprint(descs[i][1]),
+			Value:    item.Value,
+			Code:     item.Code,
+			Reason:   item.Reason,
+		}
+	}
+	return values, specs.Description, err
+}
+
+// propValue is one property read by `get`
+type propValue struct {
+	Did      string      `json:"did"`
+	Siid     int         `json:"siid"`
+	Piid     int         `json:"piid"`
+	Service  string      `json:"service"`
+	Property string      `json:"property"`
+	Value    interface{} `json:"value"`
+	Code     int         `json:"code"`
+	Reason   string      `json:"reason,omitempty"`
 }
 
 func init() {
-	propsGetCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID")
-	propsGetCmd.Example = "  get 1,1-2,1-3,1-4,2-1,2-2,3"
+	propsGetCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID, name or room/name, comma separated for several devices")
+	addLocalFlags(propsGetCmd)
+	addSelectorFlags(propsGetCmd)
+	propsGetCmd.Example = "  get 1,1-2,1-3,1-4,2-1,2-2,3\n  get light.brightness light.on\n  get --local 2-1,2-2\n  get --model 'yeelink.light.*' light.on"
 }
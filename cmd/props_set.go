@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
-	"strings"
 
 	"micli/internal/conf"
 	"micli/pkg/miservice"
@@ -16,88 +15,115 @@ import (
 )
 
 var propsSetCmd = &cobra.Command{
-	Use:   "set <siid[-piid]=[#]value>[,...]",
+	Use:   "set <siid[-piid]|service.property=[#]value>[,...]",
 	Short: "MIoT Properties Set",
 	Long:  `MIoT Properties Set`,
-	Run: func(cmd *cobra.Command, args []string) {
-		var (
-			res interface{}
-			err error
-		)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		devices, err := selectDevices()
+		if err != nil {
+			return err
+		}
+		if devices != nil {
+			return runBatch(devices, func(device *miservice.DeviceInfo) (interface{}, string, error) {
+				results, err := writeProps(device, args)
+				if err != nil {
+					return results, "", err
+				}
+				if failed := lo.Filter(results, func(r *miservice.PropResult, _ int) bool { return r.Code != 0 }); len(failed) > 0 {
+					return results, "", fmt.Errorf("%d-%d=%v failed: %w", failed[0].Siid, failed[0].Piid, failed[0].Value, failed[0].Err())
+				}
+				return results, "success", nil
+			})
+		}
 		if did == "" {
-			did = conf.Cfg.Section("account").Key("MI_DID").MustString("")
+			did = conf.Account().Key("MI_DID").MustString("")
 			if did == "" {
 				did, err = chooseDevice()
 				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
+					return err
 				}
 			}
 		}
-		if !util.IsDigit(did) {
-			var devices []*miservice.DeviceInfo
-			devices, err = getDeviceListFromLocal()
-			if err != nil {
-				pterm.Error.Println(err.Error())
-				return
-			}
-			if len(devices) == 0 {
-				err = fmt.Errorf("no device found")
-				pterm.Error.Println(err.Error())
-				return
-			}
-			for _, device := range devices {
-				if device.Name == did {
-					did = device.Did
-					break
-				}
-			}
+		var device *miservice.DeviceInfo
+		device, err = resolveDevice(did)
+		if err != nil {
+			return err
 		}
-
-		miot := true
-		var props [][]interface{}
-		var _args []string
-		if len(args) > 0 {
-			_args = strings.Split(args[0], ",")
+		var results []*miservice.PropResult
+		results, err = writeProps(device, args)
+		if err != nil {
+			return err
 		}
-		for _, item := range _args {
-			key, value := util.TwinsSplit(item, "=", "1")
-			siid, iid := util.TwinsSplit(key, "-", "1")
-			var prop []interface{}
-			if util.IsDigit(siid) && util.IsDigit(iid) {
-				s, _ := strconv.Atoi(siid)
-				i, _ := strconv.Atoi(iid)
-				prop = []interface{}{s, i}
-			} else {
-				prop = []interface{}{key}
-				miot = false
+		failed := lo.Filter(results, func(r *miservice.PropResult, _ int) bool { return r.Code != 0 })
+		err = render(results, func() error {
+			if len(failed) == 0 {
+				pterm.NewStyle(pterm.FgGreen).Println("success.")
+				return nil
 			}
-			prop = append(prop, util.StringOrValue(value))
-			props = append(props, prop)
+			for _, r := range failed {
+				pterm.NewStyle(pterm.FgRed).Printf("%d-%d=%v failed: %s (%d)\n", r.Siid, r.Piid, r.Value, r.Reason, r.Code)
+			}
+			return nil
+		})
+		if err == nil && len(failed) > 0 {
+			err = failed[0].Err()
 		}
+		return err
+	},
+}
 
-		if miot {
-			var data []float64
-			data, err = ioSrv.MiotSetProps(did, props)
-			arr := lo.Filter(data, func(item float64, index int) bool { return item != 0 })
-			if len(arr) > 0 {
-				err = errors.New("set failed")
-			}
-			res = "success."
+// writeProps parses and validates the key=value args against the spec of a
+// device and writes them
+func writeProps(device *miservice.DeviceInfo, args []string) ([]*miservice.PropResult, error) {
+	var (
+		props [][]interface{}
+		specs *miservice.MiotSpecInstancesData
+		err   error
+	)
+	for _, item := range splitArgs(args) {
+		key, value := util.TwinsSplit(item, "=", "1")
+		siid, iid := util.TwinsSplit(key, "-", "1")
+		var prop []interface{}
+		if util.IsDigit(siid) && util.IsDigit(iid) {
+			s, _ := strconv.Atoi(siid)
+			i, _ := strconv.Atoi(iid)
+			prop = []interface{}{s, i}
 		} else {
-			/*var _props map[string]interface{}
-			for _, prop := range props {
-				_props[prop[0].(string)] = prop[1]
+			// names need the spec even with --force
+			if specs == nil {
+				specs, err = ioSrv.MiotSpec(device.Model)
+				if err != nil {
+					return nil, err
+				}
+			}
+			_service, _prop, err := specs.ResolveProperty(key)
+			if err != nil {
+				return nil, err
 			}
-			res, err = ioSrv.HomeSetProps(did, _props)*/
-			err = errors.New("device not support miot")
+			prop = []interface{}{_service.Iid, _prop.Iid}
 		}
-
-		handleResult(res, err)
-	},
+		prop = append(prop, util.StringOrValue(value))
+		props = append(props, prop)
+	}
+	if len(props) == 0 {
+		return nil, errors.New("no property to set")
+	}
+	if force {
+		specs = nil
+	} else if specs == nil {
+		specs = loadDeviceSpec(device.Model)
+	}
+	if err = validateProps(specs, props); err != nil {
+		return nil, err
+	}
+	useLocal(device.Did)
+	return ioSrv.MiotSetProps(device.Did, props)
 }
 
 func init() {
-	propsSetCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID")
-	propsSetCmd.Example = "  set 2=60,2-2=false,3=test"
+	propsSetCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID, name or room/name, comma separated for several devices")
+	addLocalFlags(propsSetCmd)
+	addSelectorFlags(propsSetCmd)
+	addValidateFlags(propsSetCmd)
+	propsSetCmd.Example = "  set 2=60,2-2=false,3=test\n  set light.on=true light.brightness=50\n  set 2-2=Sleep\n  set --clamp 2-3=120\n  set --local --ip 192.168.1.20 2-1=true\n  set --model 'yeelink.light.*' light.on=false\n  set --room Bedroom --name-match lamp light.brightness=30"
 }
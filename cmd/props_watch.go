@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"reflect"
+	"runtime"
+	"syscall"
+	"time"
+
+	"micli/internal/conf"
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+	watchExec     string
+	propsWatchCmd = &cobra.Command{
+		Use:   "watch <siid[-piid]|service.property>[,...]",
+		Short: "MIoT Properties Watch",
+		Long: `Poll properties and print a line whenever a value changes.
+With -o json every change is a JSON line. --exec runs a command on each change,
+with MICLI_DID, MICLI_SIID, MICLI_PIID, MICLI_PROPERTY, MICLI_OLD, MICLI_NEW
+and MICLI_TIME in its environment.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if watchInterval <= 0 {
+				return fmt.Errorf("invalid interval %s", watchInterval)
+			}
+			if did == "" {
+				did = conf.Account().Key("MI_DID").MustString("")
+				if did == "" {
+					did, err = chooseDevice()
+					if err != nil {
+						return err
+					}
+				}
+			}
+			var device *miservice.DeviceInfo
+			device, err = resolveDevice(did)
+			if err != nil {
+				return err
+			}
+			var specs *miservice.MiotSpecInstancesData
+			specs, err = ioSrv.MiotSpec(device.Model)
+			if err != nil {
+				return err
+			}
+			var (
+				props [][]interface{}
+				refs  []*watchEvent
+				specP []*miservice.MiotSpecProperty
+			)
+			for _, item := range splitArgs(args) {
+				_service, _prop, err := specs.ResolveProperty(item)
+				if err != nil {
+					return err
+				}
+				props = append(props, []interface{}{_service.Iid, _prop.Iid})
+				refs = append(refs, &watchEvent{Did: device.Did, Siid: _service.Iid, Piid: _prop.Iid, Service: _service.Description, Property: _prop.Description})
+				specP = append(specP, _prop)
+			}
+			useLocal(device.Did)
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			pterm.Info.Printf("Watching %d properties of %s every %s, Ctrl+C to stop\n", len(props), device.Name, watchInterval)
+
+			last := make([]*miservice.PropResult, len(props))
+			errs := pollErrors{}
+			return poll(ctx, watchInterval, func(now time.Time) error {
+				data, err := ioSrv.MiotGetPropsBatched(device.Did, props)
+				errs.report(device.Did, "Fail to read properties", err)
+				for i, r := range data {
+					r.Value = specP[i].Decode(r.Value)
+					prev := last[i]
+					last[i] = r
+					if prev != nil && prev.Code == r.Code && reflect.DeepEqual(prev.Value, r.Value) {
+						continue
+					}
+					ev := *refs[i]
+					ev.Time = now.Format(time.DateTime)
+					ev.New, ev.Text, ev.Code, ev.Reason = r.Value, specP[i].ValueText(r.Value), r.Code, r.Reason
+					if prev != nil {
+						ev.Old = prev.Value
+					}
+					if err := printWatchEvent(&ev); err != nil {
+						return err
+					}
+					// the first reading is the baseline, not a change
+					if prev != nil && watchExec != "" {
+						runWatchHook(ctx, &ev)
+					}
+				}
+				return nil
+			})
+		},
+	}
+)
+
+// poll calls fn now and then every interval until ctx is done or fn fails
+func poll(ctx context.Context, interval time.Duration, fn func(now time.Time) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := fn(time.Now()); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollErrors reports a polling error only when it differs from the previous
+// one of the same key, so a device that stays unreachable is reported once
+type pollErrors map[string]string
+
+func (p pollErrors) report(key, msg string, err error) {
+	if err == nil {
+		delete(p, key)
+		return
+	}
+	if p[key] != err.Error() {
+		pterm.Warning.Printf("%s: %v\n", msg, err)
+	}
+	p[key] = err.Error()
+}
+
+// watchEvent is a property value change seen by `watch`
+type watchEvent struct {
+	Time     string      `json:"time"`
+	Did      string      `json:"did"`
+	Siid     int         `json:"siid"`
+	Piid     int         `json:"piid"`
+	Service  string      `json:"service"`
+	Property string      `json:"property"`
+	Old      interface{} `json:"old"`
+	New      interface{} `json:"new"`
+	Text     string      `json:"text,omitempty"`
+	Code     int         `json:"code,omitempty"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+// printWatchEvent prints a change as a JSON line with -o json, else as a human readable line
+func printWatchEvent(ev *watchEvent) error {
+	if outputFormat == outputJSON {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(os.Stdout, string(b))
+		return err
+	}
+	value := fmt.Sprint(ev.New)
+	if ev.Text != "" {
+		value = fmt.Sprintf("%v (%s)", ev.New, ev.Text)
+	}
+	if ev.Code != 0 {
+		value = pterm.Red(fmt.Sprintf("%s (%d)", ev.Reason, ev.Code))
+	}
+	change := pterm.Green(value)
+	if ev.Old != nil {
+		change = fmt.Sprintf("%v -> %s", ev.Old, pterm.Green(value))
+	}
+	_, err := fmt.Fprintf(os.Stdout, "%s %s/%s: %s\n", pterm.Gray(ev.Time), pterm.Cyan(ev.Service), ev.Property, change)
+	return err
+}
+
+// runWatchHook runs --exec for a change, a failing hook is only reported
+func runWatchHook(ctx context.Context, ev *watchEvent) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	c := exec.CommandContext(ctx, shell, flag, watchExec)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	c.Env = append(os.Environ(),
+		"MICLI_DID="+ev.Did,
+		fmt.Sprintf("MICLI_SIID=%d", ev.Siid),
+		fmt.Sprintf("MICLI_PIID=%d", ev.Piid),
+		"MICLI_PROPERTY="+ev.Property,
+		fmt.Sprintf("MICLI_OLD=%v", ev.Old),
+		fmt.Sprintf("MICLI_NEW=%v", ev.New),
+		"MICLI_TIME="+ev.Time,
+	)
+	if err := c.Run(); err != nil && ctx.Err() == nil {
+		pterm.Warning.Printf("Hook failed: %v\n", err)
+	}
+}
+
+func init() {
+	propsWatchCmd.Flags().StringVarP(&did, "did", "d", "", "Device ID, name or room/name")
+	propsWatchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Second, "poll interval")
+	propsWatchCmd.Flags().StringVar(&watchExec, "exec", "", "command to run on every change")
+	addLocalFlags(propsWatchCmd)
+	propsWatchCmd.Example = "  watch 2-1,2-2\n  watch --interval 10s environment.temperature\n  watch -o json light.on | jq .new\n  watch --exec 'notify-send \"$MICLI_PROPERTY: $MICLI_NEW\"' 2-1"
+}
@@ -5,7 +5,6 @@ import (
 	"micli/pkg/miservice"
 
 	"fmt"
-	"os"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -17,34 +16,37 @@ var qrLoginCmd = &cobra.Command{
 	Long: `Login using QR code scan with Mi Home app.
 This avoids captcha issues when username/password login requires verification.
 The authentication token will be saved for future use.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		tokenPath := fmt.Sprintf("%s/.mi.token", os.Getenv("HOME"))
+	RunE: func(cmd *cobra.Command, args []string) error {
 		tokenStore := ms.GetTokenStore()
 		if tokenStore == nil {
-			tokenStore = miservice.NewTokenStore(tokenPath)
+			var err error
+			if tokenStore, err = newTokenStore(); err != nil {
+				return err
+			}
 		}
 
 		// Clear existing token to force fresh QR login
 		_ = tokenStore.SaveToken(nil)
 
 		// Create a fresh service for QR login
+		pass, _ := conf.Password()
 		qrService := miservice.New(
-			conf.Cfg.Section("account").Key("MI_USER").MustString(""),
-			conf.Cfg.Section("account").Key("MI_PASS").MustString(""),
-			conf.Cfg.Section("account").Key("REGION").MustString("cn"),
+			conf.Account().Key("MI_USER").MustString(""),
+			pass,
+			conf.Account().Key("REGION").MustString("cn"),
 			tokenStore,
 		)
 
 		pterm.Info.Println("Starting QR code login...")
 		token, err := qrService.QRLogin()
 		if err != nil {
-			pterm.Error.Printf("QR login failed: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("QR login failed: %w", err)
 		}
 
 		pterm.Success.Println("Login successful!")
 		pterm.Info.Printf("User ID: %s\n", token.UserId)
 		pterm.Info.Printf("Login mode: %s\n", token.LoginMode)
+		return nil
 	},
 }
 
@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"micli/internal/conf"
+	"micli/pkg/miservice"
+	"micli/pkg/record"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// defaultRecordDB is data/profiles/<name>/record.db for other profiles, see initConf
+var defaultRecordDB = "./data/record.db"
+
+var (
+	recordDB       string
+	recordInterval time.Duration
+	recordOnce     bool
+	recordFrom     string
+	recordTo       string
+	recordStep     string
+	recordStats    bool
+	recordCSV      bool
+	recordCmd      = &cobra.Command{
+		Use:   "record [device:]<siid-piid|service.property>[,...] ...",
+		Short: "Record property values to SQLite",
+		Long: `Sample numeric and boolean properties of one or more devices on a schedule
+and store them in a local SQLite database, keyed by did, siid and piid.
+A target without "device:" uses the default device. Booleans are stored as 0 and 1.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if recordInterval <= 0 && !recordOnce {
+				return fmt.Errorf("invalid interval %s", recordInterval)
+			}
+			targets, err := recordTargets(args)
+			if err != nil {
+				return err
+			}
+			for _, t := range targets {
+				for _, p := range t.props {
+					if p.Format == "string" {
+						return fmt.Errorf("%s of %s is a string and can not be recorded", p.Description, t.device.Name)
+					}
+				}
+			}
+			store, err := openRecordDB()
+			if err != nil {
+				return err
+			}
+			defer func(store *record.Store) {
+				_ = store.Close()
+			}(store)
+			count := 0
+			for _, t := range targets {
+				for _, s := range t.series {
+					if err = store.AddSeries(s); err != nil {
+						return err
+					}
+				}
+				count += len(t.series)
+				useLocal(t.device.Did)
+			}
+
+			errs := pollErrors{}
+			sample := func(now time.Time) error {
+				var samples []*record.Sample
+				for _, t := range targets {
+					data, err := ioSrv.MiotGetPropsBatched(t.device.Did, t.query)
+					errs.report(t.device.Did, fmt.Sprintf("Fail to read %s (%s)", t.device.Name, t.device.Did), err)
+					for i, r := range data {
+						if r.Code != 0 {
+							continue
+						}
+						if v, ok := record.Value(t.props[i].Decode(r.Value)); ok {
+							samples = append(samples, &record.Sample{Key: t.series[i].Key, Time: now, Value: v})
+						}
+					}
+				}
+				if len(samples) == 0 {
+					return nil
+				}
+				return store.Add(samples)
+			}
+			if recordOnce {
+				return sample(time.Now())
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			pterm.Info.Printf("Recording %d properties of %d devices every %s into %s, Ctrl+C to stop\n", count, len(targets), recordInterval, recordDBPath())
+			return poll(ctx, recordInterval, sample)
+		},
+	}
+	recordQueryCmd = &cobra.Command{
+		Use:   "query [[device:]<siid-piid|service.property>[,...] ...]",
+		Short: "Query recorded values",
+		Long: `Print recorded values of the targets, or of every recorded property.
+--step aggregates samples into buckets with count, min, max and average,
+--stats aggregates the whole range. --from and --to take a time such as
+"2024-05-01 08:00" or a duration ago such as 24h or 7d.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			now := time.Now()
+			from, err := parseRecordTime(recordFrom, now)
+			if err != nil {
+				return fmt.Errorf("invalid --from: %w", err)
+			}
+			to, err := parseRecordTime(recordTo, now)
+			if err != nil {
+				return fmt.Errorf("invalid --to: %w", err)
+			}
+			step, err := parseRecordDuration(recordStep)
+			if err != nil {
+				return fmt.Errorf("invalid --step: %w", err)
+			}
+			store, err := openRecordDB()
+			if err != nil {
+				return err
+			}
+			defer func(store *record.Store) {
+				_ = store.Close()
+			}(store)
+			series, err := store.Series()
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				targets, err := recordTargets(args)
+				if err != nil {
+					return err
+				}
+				known := make(map[record.Key]*record.Series, len(series))
+				for _, s := range series {
+					known[s.Key] = s
+				}
+				series = series[:0]
+				for _, t := range targets {
+					for _, s := range t.series {
+						if k, ok := known[s.Key]; ok {
+							series = append(series, k)
+						} else {
+							pterm.Warning.Printf("%s of %s was never recorded\n", s.Name, s.Device)
+						}
+					}
+				}
+			}
+
+			var rows []*recordRow
+			for _, s := range series {
+				var points []*record.Point
+				if recordStats {
+					var p *record.Point
+					if p, err = store.Stats(s.Key, from, to); p != nil {
+						points = []*record.Point{p}
+					}
+				} else {
+					points, err = store.Points(s.Key, from, to, step)
+				}
+				if err != nil {
+					return err
+				}
+				for _, p := range points {
+					rows = append(rows, &recordRow{Series: s, Point: p})
+				}
+			}
+			raw := !recordStats && step < time.Second
+			if recordCSV {
+				return writeRecordCSV(rows, raw)
+			}
+			return render(rows, func() error {
+				if len(rows) == 0 {
+					pterm.Info.Println("No sample in range")
+					return nil
+				}
+				header := []string{"Time", "Device", "Property", "Count", "Min", "Max", "Avg", "Unit"}
+				if raw {
+					header = []string{"Time", "Device", "Property", "Value", "Unit"}
+				}
+				data := pterm.TableData{header}
+				for _, r := range rows {
+					unit := r.Series.Unit
+					if unit == "none" {
+						unit = ""
+					}
+					row := []string{r.Point.Time.Format(time.DateTime), r.Series.Device, r.Series.Name}
+					if raw {
+						row = append(row, formatSample(r.Point.Avg), unit)
+					} else {
+						row = append(row, strconv.Itoa(r.Point.Count), formatSample(r.Point.Min), formatSample(r.Point.Max), formatSample(r.Point.Avg), unit)
+					}
+					data = append(data, row)
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+		},
+	}
+	recordListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List recorded properties",
+		Long:  `List recorded properties with their sample count and time span`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openRecordDB()
+			if err != nil {
+				return err
+			}
+			defer func(store *record.Store) {
+				_ = store.Close()
+			}(store)
+			series, err := store.Series()
+			if err != nil {
+				return err
+			}
+			return render(series, func() error {
+				data := pterm.TableData{{"Device", "Did", "Id", "Property", "Samples", "First", "Last"}}
+				for _, s := range series {
+					first, last := "", ""
+					if s.Count > 0 {
+						first, last = s.First.Format(time.DateTime), s.Last.Format(time.DateTime)
+					}
+					data = append(data, []string{s.Device, s.Did, fmt.Sprintf("%d-%d", s.Siid, s.Piid), s.Name, strconv.Itoa(s.Count), first, last})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+		},
+	}
+)
+
+// recordTarget is the properties of one device given to `record`
+type recordTarget struct {
+	device *miservice.DeviceInfo
+	query  [][]interface{}
+	props  []*miservice.MiotSpecProperty
+	series []*record.Series
+}
+
+// recordRow is one aggregated value printed by `record query`
+type recordRow struct {
+	Series *record.Series `json:"series"`
+	Point  *record.Point  `json:"point"`
+}
+
+// recordTargets resolves "[device:]props" arguments, the properties of a
+// device named several times are merged
+func recordTargets(args []string) ([]*recordTarget, error) {
+	var targets []*recordTarget
+	byDid := make(map[string]*recordTarget)
+	for _, arg := range args {
+		selector, props := "", arg
+		if i := strings.LastIndex(arg, ":"); i >= 0 {
+			selector, props = arg[:i], arg[i+1:]
+		}
+		if selector == "" {
+			selector = conf.Account().Key("MI_DID").MustString("")
+			if selector == "" {
+				var err error
+				if selector, err = chooseDevice(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		device, err := resolveDevice(selector)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := byDid[device.Did]
+		if !ok {
+			t = &recordTarget{device: device}
+			byDid[device.Did] = t
+			targets = append(targets, t)
+		}
+		items := splitArgs([]string{props})
+		if len(items) == 0 {
+			return nil, fmt.Errorf("no property given for %s", selector)
+		}
+		specs, err := ioSrv.MiotSpec(device.Model)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			_service, _prop, err := specs.ResolveProperty(item)
+			if err != nil {
+				return nil, err
+			}
+			t.query = append(t.query, []interface{}{_service.Iid, _prop.Iid})
+			t.props = append(t.props, _prop)
+			t.series = append(t.series, &record.Series{
+				Key:    record.Key{Did: device.Did, Siid: _service.Iid, Piid: _prop.Iid},
+				Device: device.Name,
+				Name:   miservice.ShortName(_service.Type) + "." + miservice.ShortName(_prop.Type),
+				Unit:   _prop.Unit,
+			})
+		}
+	}
+	return targets, nil
+}
+
+// recordDBPath returns --db, then [record] DB of the config, then defaultRecordDB
+func recordDBPath() string {
+	if recordDB != "" {
+		return recordDB
+	}
+	return conf.Cfg.Section("record").Key("DB").MustString(defaultRecordDB)
+}
+
+func openRecordDB() (*record.Store, error) {
+	return record.Open(recordDBPath())
+}
+
+// parseRecordTime parses a time, "now", or a duration ago such as 24h or 7d
+func parseRecordTime(s string, now time.Time) (time.Time, error) {
+	if s == "" || s == "now" {
+		return now, nil
+	}
+	if d, err := parseRecordDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a time nor a duration", s)
+}
+
+// parseRecordDuration is time.ParseDuration with a "d" suffix for days
+func parseRecordDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("invalid number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func formatSample(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// writeRecordCSV writes rows as CSV, raw samples have a single value column
+func writeRecordCSV(rows []*recordRow, raw bool) error {
+	w := csv.NewWriter(os.Stdout)
+	header := []string{"time", "did", "siid", "piid", "device", "property", "unit", "count", "min", "max", "avg"}
+	if raw {
+		header = []string{"time", "did", "siid", "piid", "device", "property", "unit", "value"}
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		s, p := r.Series, r.Point
+		row := []string{p.Time.Format(time.RFC3339), s.Did, strconv.Itoa(s.Siid), strconv.Itoa(s.Piid), s.Device, s.Name, s.Unit}
+		if raw {
+			row = append(row, formatSample(p.Avg))
+		} else {
+			row = append(row, strconv.Itoa(p.Count), formatSample(p.Min), formatSample(p.Max), formatSample(p.Avg))
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func init() {
+	recordCmd.PersistentFlags().StringVar(&recordDB, "db", "", "SQLite database, defaults to [record] DB of the config or "+defaultRecordDB)
+	recordCmd.Flags().DurationVar(&recordInterval, "interval", time.Minute, "sampling interval")
+	recordCmd.Flags().BoolVar(&recordOnce, "once", false, "take one sample and exit, e.g. from cron")
+	addLocalFlags(recordCmd)
+	recordQueryCmd.Flags().StringVar(&recordFrom, "from", "24h", "start time or duration ago")
+	recordQueryCmd.Flags().StringVar(&recordTo, "to", "now", "end time or duration ago")
+	recordQueryCmd.Flags().StringVar(&recordStep, "step", "", "downsample into buckets of this size, e.g. 15m, 1h, 1d")
+	recordQueryCmd.Flags().BoolVar(&recordStats, "stats", false, "count, min, max and average over the whole range")
+	recordQueryCmd.Flags().BoolVar(&recordCSV, "csv", false, "write CSV instead of --output")
+	recordCmd.AddCommand(recordQueryCmd, recordListCmd)
+	recordCmd.Example = "  record --interval 5m Bedroom/Sensor:environment.temperature,environment.relative-humidity Plug:electric-power\n  record --once 3-1\n  record query --from 7d --step 1h Plug:electric-power\n  record query --stats --from 2024-05-01 --csv > summary.csv\n  record list"
+}
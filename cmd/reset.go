@@ -11,12 +11,15 @@ var resetCmd = &cobra.Command{
 	Use:   "reset",
 	Short: "Config Reset",
 	Long:  `Config Reset`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := conf.RequirePrompt("reset asks for confirmation"); err != nil {
+			return err
+		}
 		confirm, _ := pterm.DefaultInteractiveConfirm.Show("Are you sure to reset config file?")
 		if confirm {
 			conf.Reset()
 			pterm.Info.Println("Config file has been reset.")
-			return
 		}
+		return nil
 	},
 }
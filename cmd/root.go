@@ -3,12 +3,14 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"micli/internal/conf"
 	"micli/pkg/miservice"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pterm/pterm"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"gopkg.in/ini.v1"
 )
@@ -16,35 +18,55 @@ import (
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 var (
-	ms           *miservice.Service
-	ioSrv        *miservice.IOService
-	minaSrv      *miservice.MinaService
-	did          string
-	minaDeviceID string
-	rootCmd      = &cobra.Command{
+	ms            *miservice.Service
+	ioSrv         *miservice.IOService
+	minaSrv       *miservice.MinaService
+	did           string
+	profileName   string
+	noInteractive bool
+	minaDeviceID  string
+	rootCmd       = &cobra.Command{
 		Version: "1.0.0",
 		Use:     "micli",
 		Short:   "Take XiaoMi Cloud Service to the command line",
 		Long: `
 MiCLI brings XiaoMi Cloud Service to your terminal. 
 Free and open source.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			commandStarted = true
+			if needsAccount(cmd) {
+				initAccount()
+			}
+		},
 	}
 )
 
 func Execute() {
-	err := rootCmd.Execute()
+	cmd, err := rootCmd.ExecuteC()
 	if err != nil {
-		os.Exit(1)
+		pterm.Error.Println(err.Error())
+		if !commandStarted {
+			cmd.PrintErrln(cmd.UsageString())
+		}
+		os.Exit(exitStatus(err))
 	}
 }
 
 func init() {
-	cobra.OnInitialize(initConf)
+	cobra.OnInitialize(initOutput, initConf)
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "output format: table|json|yaml|plain")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "account profile, defaults to [app] PROFILE of the config")
+	rootCmd.PersistentFlags().BoolVar(&noInteractive, "no-interactive", false, "never prompt, fail when input is missing")
 	rootCmd.AddCommand(resetCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(specCmd)
 	rootCmd.AddCommand(propsGetCmd)
 	rootCmd.AddCommand(propsSetCmd)
+	rootCmd.AddCommand(propsDumpCmd)
+	rootCmd.AddCommand(propsWatchCmd)
+	rootCmd.AddCommand(recordCmd)
 	rootCmd.AddCommand(actionCmd)
 	rootCmd.AddCommand(decodeCmd)
 	rootCmd.AddCommand(minaCmd)
@@ -52,6 +74,12 @@ func init() {
 	rootCmd.AddCommand(miioRawCmd)
 	rootCmd.AddCommand(setDidCmd)
 	rootCmd.AddCommand(ttsCmd)
+	rootCmd.AddCommand(discoverCmd)
+	rootCmd.AddCommand(homeCmd)
+	rootCmd.AddCommand(sceneCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(authCmd)
 }
 
 func initConf() {
@@ -65,38 +93,107 @@ func initConf() {
 		pterm.Error.Printf("Fail to read config file: %v", err)
 		os.Exit(1)
 	}
-	err = conf.Complete()
+	conf.Interactive = !noInteractive
+	if err = conf.SelectProfile(profileName); err != nil {
+		pterm.Error.Println(err.Error())
+		os.Exit(exitUsage)
+	}
+	if conf.Profile != "" {
+		dir := filepath.Join("data", "profiles", conf.Profile)
+		devicesPath, homesPath = filepath.Join(dir, "devices.json"), filepath.Join(dir, "homes.json")
+		snapshotsDir = filepath.Join(dir, "snapshots")
+		// the LAN tokens of discover belong to the devices of this account
+		discoveryPath = filepath.Join(dir, "discovery.json")
+		defaultRecordDB = filepath.Join(dir, "record.db")
+	}
+}
+
+// annotationNoAccount marks commands, and their subcommands, that work on the
+// config alone and must not ask for missing account details
+const annotationNoAccount = "no-account"
+
+func needsAccount(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if _, ok := c.Annotations[annotationNoAccount]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// initAccount completes the account of the active profile and sets up the services
+func initAccount() {
+	err := conf.Complete()
+	if err != nil {
+		pterm.Error.Println(err.Error())
+		os.Exit(exitStatus(err))
+	}
+	tokenStore, err := newTokenStore()
 	if err != nil {
 		pterm.Error.Println(err.Error())
 		os.Exit(1)
 	}
-	tokenPath := fmt.Sprintf("%s/.mi.token", os.Getenv("HOME"))
+	// a password that can not be resolved only matters once the token expires
+	pass, err := conf.Password()
+	if err != nil {
+		pterm.Warning.Printf("Fail to resolve MI_PASS: %v\n", err)
+	}
 	ms = miservice.New(
-		conf.Cfg.Section("account").Key("MI_USER").MustString(""),
-		conf.Cfg.Section("account").Key("MI_PASS").MustString(""),
-		conf.Cfg.Section("account").Key("REGION").MustString("cn"),
-		miservice.NewTokenStore(tokenPath),
+		conf.Account().Key("MI_USER").MustString(""),
+		pass,
+		conf.Account().Key("REGION").MustString("cn"),
+		tokenStore,
 	)
+	// under systemd or docker nobody watches the terminal QR code
+	ms.SetInteractive(conf.CanPrompt())
+	ms.SetCaptchaHandler(promptCaptcha)
+	ms.SetVerifyHandler(promptVerifyCode)
 	ioSrv = miservice.NewIOService(ms)
+	ioSrv.SetSpecCache(newSpecCache())
 	minaSrv = miservice.NewMinaService(ms)
+	initRoutes()
+}
+
+// newTokenStore returns the token backend selected by [token] STORE, each
+// profile has its own file or keyring entry
+func newTokenStore() (miservice.TokenStore, error) {
+	suffix := conf.ProfileSuffix()
+	path := conf.Cfg.Section("token").Key("PATH").MustString("")
+	if path != "" {
+		path += suffix
+	}
+	switch backend := conf.TokenBackend(); backend {
+	case "file", "":
+		return miservice.NewTokenStore(lo.CoalesceOrEmpty(path, fmt.Sprintf("%s/.mi%s.token", os.Getenv("HOME"), suffix))), nil
+	case "encrypted":
+		return miservice.NewEncryptedTokenStore(lo.CoalesceOrEmpty(path, fmt.Sprintf("%s/.mi%s.token.enc", os.Getenv("HOME"), suffix)), conf.Passphrase), nil
+	case "keyring":
+		return miservice.NewKeyringTokenStore(conf.KeyringService, "token"+suffix), nil
+	case "env":
+		return miservice.NewEnvTokenStore(conf.TokenEnv), nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q, use file|encrypted|keyring|env", backend)
+	}
 }
 
-func handleResult(res interface{}, err error) {
+func handleResult(res interface{}, err error) error {
 	if err != nil {
-		pterm.Error.Println(err.Error())
-	} else {
-		if res == nil {
-			return
-		}
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+	return render(res, func() error {
 		if resStr, ok := res.(string); ok {
 			pterm.NewStyle(pterm.FgGreen).Println(resStr)
+			return nil
+		}
+		resBytes, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			pterm.Warning.Printf("Failed to marshal result: %v, raw: %v", err, res)
 		} else {
-			resBytes, err := json.MarshalIndent(res, "", "  ")
-			if err != nil {
-				pterm.Warning.Printf("Failed to marshal result: %v, raw: %v", err, res)
-			} else {
-				pterm.NewStyle(pterm.FgGreen).Println(string(resBytes))
-			}
+			pterm.NewStyle(pterm.FgGreen).Println(string(resBytes))
 		}
-	}
+		return nil
+	})
 }
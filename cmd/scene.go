@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sceneHome string
+	sceneCmd  = &cobra.Command{
+		Use:   "scene",
+		Short: "Mi Home scenes",
+		Long:  `Mi Home scenes`,
+	}
+	sceneListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List scenes",
+		Long:  `List scenes`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scenes, err := getSceneList()
+			if err != nil {
+				return err
+			}
+			err = render(scenes, func() error {
+				data := pterm.TableData{{"Scene", "ID", "Home"}}
+				for _, scene := range scenes {
+					data = append(data, []string{scene.Name, scene.ID, scene.HomeID})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+			return err
+		},
+	}
+	sceneRunCmd = &cobra.Command{
+		Use:   "run <id|name>",
+		Short: "Run a scene",
+		Long:  `Run a scene`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				res interface{}
+				err error
+			)
+			if len(args) < 1 {
+				return errors.New("scene id or name is empty")
+			}
+			var scene *miservice.SceneInfo
+			scene, err = findScene(args[0])
+			if err == nil {
+				err = ioSrv.RunScene(scene.ID)
+			}
+			if err == nil {
+				res = fmt.Sprintf("scene %s triggered.", scene.Name)
+			}
+			return handleResult(res, err)
+		},
+	}
+)
+
+func init() {
+	sceneCmd.PersistentFlags().StringVar(&sceneHome, "home", "", "home id or name, defaults to all homes")
+	sceneCmd.AddCommand(sceneListCmd)
+	sceneCmd.AddCommand(sceneRunCmd)
+	sceneCmd.Example = "  scene list\n  scene run 回家\n  scene run 1234567890"
+}
+
+// getSceneList collects the scenes of every home, or only of --home
+func getSceneList() (scenes []*miservice.SceneInfo, err error) {
+	var homes []*miservice.HomeInfo
+	homes, err = getHomeList()
+	if err != nil {
+		return
+	}
+	for _, home := range homes {
+		if sceneHome != "" && home.ID != sceneHome && home.Name != sceneHome {
+			continue
+		}
+		var list []*miservice.SceneInfo
+		list, err = ioSrv.SceneList(home.ID)
+		if err != nil {
+			return
+		}
+		scenes = append(scenes, list...)
+	}
+	return
+}
+
+// findScene looks a scene up by id, then by name
+func findScene(key string) (*miservice.SceneInfo, error) {
+	scenes, err := getSceneList()
+	if err != nil {
+		return nil, err
+	}
+	if scene, ok := lo.Find(scenes, func(s *miservice.SceneInfo) bool { return s.ID == key }); ok {
+		return scene, nil
+	}
+	matches := lo.Filter(scenes, func(s *miservice.SceneInfo, _ int) bool { return s.Name == key })
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("scene %q not found", key)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("scene name %q is ambiguous, use the scene id", key)
+	}
+}
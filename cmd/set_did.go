@@ -1,11 +1,8 @@
 package cmd
 
 import (
-	"fmt"
-
 	"micli/internal/conf"
 	"micli/pkg/miservice"
-	"micli/pkg/util"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -17,45 +14,31 @@ var (
 		Use:   "set_did",
 		Short: "Set the default device id",
 		Long:  `Set the default device id`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				err error
 				res interface{}
 			)
-			did = conf.Cfg.Section("account").Key("MI_DID").MustString("")
+			did = conf.Account().Key("MI_DID").MustString("")
 			if did == "" || reset {
 				did, err = chooseDevice()
 				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
+					return err
 				}
 			} else {
 				pterm.Success.Printf("The default device id is %s\n", did)
-				return
+				return nil
 			}
-			if !util.IsDigit(did) {
-				var devices []*miservice.DeviceInfo
-				devices, err = getDeviceListFromLocal()
-				if err != nil {
-					pterm.Error.Println(err.Error())
-					return
-				}
-				if len(devices) == 0 {
-					err = fmt.Errorf("no device found")
-					pterm.Error.Println(err.Error())
-					return
-				}
-				for _, device := range devices {
-					if device.Name == did {
-						did = device.Did
-						break
-					}
-				}
+			var device *miservice.DeviceInfo
+			device, err = resolveDevice(did)
+			if err != nil {
+				return err
 			}
+			did = device.Did
 			if did != "" {
 				err = conf.SetDefaultDid(did)
 			}
-			handleResult(res, err)
+			return handleResult(res, err)
 		},
 	}
 )
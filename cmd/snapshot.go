@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"micli/internal/conf"
+	"micli/pkg/miservice"
+	"micli/pkg/util"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var snapshotsDir = "./data/snapshots"
+
+// snapshot is the saved state of some devices
+type snapshot struct {
+	Name    string            `json:"name"`
+	Created int64             `json:"created"`
+	Devices []*snapshotDevice `json:"devices"`
+}
+
+type snapshotDevice struct {
+	Did   string          `json:"did"`
+	Name  string          `json:"name"`
+	Model string          `json:"model"`
+	Props []*snapshotProp `json:"props"`
+}
+
+type snapshotProp struct {
+	Siid     int         `json:"siid"`
+	Piid     int         `json:"piid"`
+	Name     string      `json:"name"`
+	Value    interface{} `json:"value"`
+	Writable bool        `json:"writable"`
+}
+
+// snapshotResult is one property of a save or restore, for the report
+type snapshotResult struct {
+	Did      string      `json:"did"`
+	Device   string      `json:"device"`
+	Property string      `json:"property"`
+	Value    interface{} `json:"value"`
+	Code     int         `json:"code"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+var (
+	snapshotCmd = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and restore device states",
+		Long: `Save and restore device states.
+A snapshot holds every readable property of the chosen devices, restoring it
+writes back the writable ones.`,
+	}
+	snapshotSaveCmd = &cobra.Command{
+		Use:   "save <name> [device...]",
+		Short: "Save the state of devices",
+		Long:  `Save the readable properties of devices (did, name or room/name), the default device when none is given`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := snapshotPath(args[0])
+			if err != nil {
+				return err
+			}
+			devices, err := snapshotDevices(args[1:])
+			if err != nil {
+				return err
+			}
+			snap := &snapshot{Name: args[0], Created: time.Now().Unix()}
+			var (
+				results []*snapshotResult
+				failed  error
+			)
+			for _, device := range devices {
+				sd, res, err := saveDevice(device)
+				if err != nil {
+					failed = fmt.Errorf("%s (%s): %w", device.Name, device.Did, err)
+					if len(devices) > 1 {
+						pterm.Error.Println(failed)
+					}
+					continue
+				}
+				snap.Devices = append(snap.Devices, sd)
+				results = append(results, res...)
+			}
+			if len(snap.Devices) == 0 {
+				return failed
+			}
+			if err = writeSnapshot(path, snap); err != nil {
+				return err
+			}
+			pterm.Success.Printf("Saved %d device(s) to %s\n", len(snap.Devices), path)
+			return renderSnapshotResults(results, failed)
+		},
+	}
+	snapshotRestoreCmd = &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Restore the state of devices",
+		Long:  `Write back the writable properties of a snapshot, each failed write is reported`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snap, err := readSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			var (
+				results []*snapshotResult
+				failed  error
+			)
+			for _, sd := range snap.Devices {
+				var props [][]interface{}
+				var names []string
+				for _, p := range sd.Props {
+					if p.Writable {
+						props = append(props, []interface{}{p.Siid, p.Piid, p.Value})
+						names = append(names, p.Name)
+					}
+				}
+				if len(props) == 0 {
+					continue
+				}
+				data, err := ioSrv.MiotSetPropsBatched(sd.Did, props)
+				if err != nil {
+					failed = fmt.Errorf("%s (%s): %w", sd.Name, sd.Did, err)
+					if len(snap.Devices) > 1 {
+						pterm.Error.Println(failed)
+					}
+				}
+				for i, r := range data {
+					results = append(results, &snapshotResult{Did: sd.Did, Device: sd.Name, Property: names[i], Value: r.Value, Code: r.Code, Reason: r.Reason})
+					if r.Code != 0 {
+						failed = r.Err()
+					}
+				}
+			}
+			return renderSnapshotResults(results, failed)
+		},
+	}
+	snapshotListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List snapshots",
+		Long:  `List snapshots`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := filepath.Glob(filepath.Join(snapshotsDir, "*.json"))
+			if err != nil {
+				return err
+			}
+			var snaps []*snapshot
+			for _, f := range files {
+				snap, err := readSnapshot(strings.TrimSuffix(filepath.Base(f), ".json"))
+				if err != nil {
+					pterm.Warning.Printf("Fail to read %s: %v\n", f, err)
+					continue
+				}
+				snaps = append(snaps, snap)
+			}
+			sort.Slice(snaps, func(i, j int) bool { return snaps[i].Name < snaps[j].Name })
+			return render(snaps, func() error {
+				data := pterm.TableData{{"Name", "Created", "Devices"}}
+				for _, s := range snaps {
+					names := make([]string, len(s.Devices))
+					for i, d := range s.Devices {
+						names[i] = d.Name
+					}
+					data = append(data, []string{s.Name, time.Unix(s.Created, 0).Format(time.DateTime), strings.Join(names, ", ")})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+		},
+	}
+)
+
+// saveDevice reads every readable property of a device
+func saveDevice(device *miservice.DeviceInfo) (*snapshotDevice, []*snapshotResult, error) {
+	specs, err := ioSrv.MiotSpec(device.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		props [][]interface{}
+		refs  []*miservice.MiotSpecProperty
+		names []string
+	)
+	for _, s := range specs.Services {
+		for _, p := range s.Properties {
+			if p.Readable() {
+				props = append(props, []interface{}{s.Iid, p.Iid})
+				refs = append(refs, p)
+				names = append(names, miservice.ShortName(s.Type)+"."+miservice.ShortName(p.Type))
+			}
+		}
+	}
+	data, err := ioSrv.MiotGetPropsBatched(device.Did, props)
+	if err != nil {
+		return nil, nil, err
+	}
+	sd := &snapshotDevice{Did: device.Did, Name: device.Name, Model: device.Model}
+	results := make([]*snapshotResult, len(data))
+	for i, r := range data {
+		results[i] = &snapshotResult{Did: device.Did, Device: device.Name, Property: names[i], Value: r.Value, Code: r.Code, Reason: r.Reason}
+		// properties the device refused to read are left out
+		if r.Code != 0 {
+			continue
+		}
+		sd.Props = append(sd.Props, &snapshotProp{Siid: r.Siid, Piid: r.Piid, Name: names[i], Value: r.Value, Writable: refs[i].Writable()})
+	}
+	return sd, results, nil
+}
+
+// snapshotDevices resolves device selectors, falling back to the default device
+func snapshotDevices(selectors []string) ([]*miservice.DeviceInfo, error) {
+	if len(selectors) == 0 {
+		selector := conf.Account().Key("MI_DID").MustString("")
+		if selector == "" {
+			var err error
+			if selector, err = chooseDevice(); err != nil {
+				return nil, err
+			}
+		}
+		selectors = []string{selector}
+	}
+	devices := make([]*miservice.DeviceInfo, len(selectors))
+	for i, s := range selectors {
+		device, err := resolveDevice(s)
+		if err != nil {
+			return nil, err
+		}
+		if device.Model == "" {
+			return nil, fmt.Errorf("device %s is not in the device cache, try `list -r`", device.Did)
+		}
+		devices[i] = device
+	}
+	return devices, nil
+}
+
+// renderSnapshotResults prints a per property report and returns failed
+func renderSnapshotResults(results []*snapshotResult, failed error) error {
+	if err := render(results, func() error {
+		if len(results) == 0 {
+			return nil
+		}
+		data := pterm.TableData{{"Device", "Property", "Value", "Result"}}
+		for _, r := range results {
+			res := pterm.Green("ok")
+			if r.Code != 0 {
+				res = pterm.Red(fmt.Sprintf("%s (%d)", r.Reason, r.Code))
+			}
+			data = append(data, []string{r.Device, r.Property, fmt.Sprint(r.Value), res})
+		}
+		return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+	}); err != nil {
+		return err
+	}
+	return failed
+}
+
+func snapshotPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return filepath.Join(snapshotsDir, name+".json"), nil
+}
+
+func readSnapshot(name string) (*snapshot, error) {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %q not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	var snap *snapshot
+	if err = json.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, err
+	}
+	// a file holding null or null entries would panic the callers
+	if snap == nil {
+		return nil, fmt.Errorf("snapshot %q is corrupted", name)
+	}
+	for _, sd := range snap.Devices {
+		if sd == nil || slices.Contains(sd.Props, nil) {
+			return nil, fmt.Errorf("snapshot %q is corrupted", name)
+		}
+	}
+	return snap, nil
+}
+
+func writeSnapshot(path string, snap *snapshot) error {
+	f, err := util.CreatNestedFile(path)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snap)
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotSaveCmd, snapshotRestoreCmd, snapshotListCmd)
+	snapshotCmd.Example = "  snapshot save movie-night Bedroom/Lamp Curtain 12345678\n  snapshot restore movie-night\n  snapshot list"
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"micli/internal/conf"
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	specPruneOlderThan time.Duration
+	specCacheCmd       = &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local MIoT spec cache",
+		Long: `Manage the local MIoT spec cache.
+Spec documents from miot-spec.org are kept in the cache dir and reused until
+they are older than the TTL ([spec] TTL in conf.ini). When miot-spec.org can
+not be reached a stale copy is used instead.`,
+		// miot-spec.org needs no login, see specIOService
+		Annotations: map[string]string{annotationNoAccount: ""},
+	}
+	specCacheListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List cached spec documents",
+		Long:  `List cached spec documents`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache := newSpecCache()
+			entries, err := cache.List()
+			if err != nil {
+				return err
+			}
+			return render(entries, func() error {
+				pterm.Info.Printf("Cache dir: %s\n", cache.Dir)
+				data := pterm.TableData{{"Model", "Type", "Version", "Fetched", "Fresh"}}
+				for _, e := range entries {
+					fresh := pterm.Green("yes")
+					if !cache.Fresh(e.FetchedAt) {
+						fresh = pterm.Red("no")
+					}
+					data = append(data, []string{e.Model, e.Type, fmt.Sprint(e.Version), time.Unix(e.FetchedAt, 0).Format(time.DateTime), fresh})
+				}
+				return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+			})
+		},
+	}
+	specCacheRefreshCmd = &cobra.Command{
+		Use:   "refresh [model|type_urn...]",
+		Short: "Download spec documents again",
+		Long:  `Download the model list and the given spec documents again, every cached document when none is given`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			io := specIOService()
+			index, err := io.RefreshSpecIndex()
+			if err != nil {
+				return err
+			}
+			type target struct{ typ, model string }
+			var targets []target
+			if len(args) == 0 {
+				var entries []*miservice.SpecEntry
+				entries, err = io.SpecCache().List()
+				if err != nil {
+					return err
+				}
+				for _, e := range entries {
+					targets = append(targets, target{e.Type, e.Model})
+				}
+			} else {
+				types := index.Types()
+				for _, arg := range args {
+					if strings.HasPrefix(arg, "urn") {
+						targets = append(targets, target{arg, ""})
+						continue
+					}
+					typ, ok := types[arg]
+					if !ok {
+						return fmt.Errorf("model %s not found on miot-spec.org", arg)
+					}
+					targets = append(targets, target{typ, arg})
+				}
+			}
+			var refreshed []*miservice.SpecEntry
+			for _, t := range targets {
+				var entry *miservice.SpecEntry
+				entry, err = io.RefreshSpec(t.typ, t.model)
+				if err != nil {
+					return err
+				}
+				refreshed = append(refreshed, entry)
+			}
+			return render(refreshed, func() error {
+				pterm.Success.Printf("Refreshed model list (%d instances) and %d spec documents\n", len(index.Instances), len(refreshed))
+				return nil
+			})
+		},
+	}
+	specCachePruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Remove superseded spec documents",
+		Long: `Remove older versions of the same spec, and with --older-than the documents
+older than that. Stale documents are kept by default, they are what is used
+when miot-spec.org can not be reached.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := newSpecCache().Prune(specPruneOlderThan)
+			if err != nil {
+				return err
+			}
+			return render(removed, func() error {
+				for _, typ := range removed {
+					pterm.Info.Println("Removed " + typ)
+				}
+				pterm.Success.Printf("Removed %d spec documents\n", len(removed))
+				return nil
+			})
+		},
+	}
+)
+
+func init() {
+	specCachePruneCmd.Flags().DurationVar(&specPruneOlderThan, "older-than", 0, "also remove documents older than this, e.g. 720h")
+	specCacheCmd.AddCommand(specCacheListCmd)
+	specCacheCmd.AddCommand(specCacheRefreshCmd)
+	specCacheCmd.AddCommand(specCachePruneCmd)
+	specCacheCmd.Example = "  spec cache list\n  spec cache refresh\n  spec cache refresh xiaomi.wifispeaker.lx06\n  spec cache prune --older-than 720h"
+}
+
+// newSpecCache returns the cache configured by [spec] CACHE_DIR and TTL
+func newSpecCache() *miservice.SpecCache {
+	return miservice.NewSpecCache(
+		conf.Cfg.Section("spec").Key("CACHE_DIR").MustString(""),
+		conf.Cfg.Section("spec").Key("TTL").MustDuration(miservice.DefaultSpecTTL),
+	)
+}
+
+// specIOService reaches miot-spec.org for the cache commands, which run
+// without an account
+func specIOService() *miservice.IOService {
+	io := miservice.NewIOService(miservice.New("", "", "", nil))
+	io.SetSpecCache(newSpecCache())
+	return io
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/pterm/pterm/putils"
+	"github.com/spf13/cobra"
+)
+
+var specDiffCmd = &cobra.Command{
+	Use:   "diff <modelA|urnA> <modelB|urnB>",
+	Short: "Compare two MIoT specs",
+	Long: `Compare two MIoT specs.
+Reports added, removed, moved (siid/piid/aiid changed) and renamed services,
+properties and actions, and changes of format, access, value range and value
+list, so the siid-piid references that break on a device upgrade are known
+in advance.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := ioSrv.MiotSpec(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := ioSrv.MiotSpec(args[1])
+		if err != nil {
+			return err
+		}
+		diff := miservice.DiffSpecs(a, b)
+		return render(diff, func() error {
+			return renderSpecDiff(diff)
+		})
+	},
+}
+
+// renderSpecDiff prints the differences of two specs as a tree
+func renderSpecDiff(diff *miservice.SpecDiff) error {
+	if diff.Empty() {
+		pterm.Success.Println("The specs are equivalent.")
+		return nil
+	}
+	var leveledList pterm.LeveledList
+	for _, s := range diff.Services {
+		leveledList = append(leveledList, pterm.LeveledListItem{Level: 0, Text: diffLine(s.Change, "siid", s.Name, s.OldName, s.OldSiid, s.NewSiid)})
+		for _, group := range []struct {
+			title   string
+			id      string
+			members []*miservice.MemberDiff
+		}{{"Properties", "piid", s.Properties}, {"Actions", "aiid", s.Actions}} {
+			if len(group.members) == 0 {
+				continue
+			}
+			leveledList = append(leveledList, pterm.LeveledListItem{Level: 1, Text: pterm.Magenta(group.title)})
+			for _, m := range group.members {
+				leveledList = append(leveledList, pterm.LeveledListItem{Level: 2, Text: diffLine(m.Change, group.id, m.Name, m.OldName, m.OldIid, m.NewIid)})
+				for _, d := range m.Details {
+					leveledList = append(leveledList, pterm.LeveledListItem{Level: 3, Text: pterm.LightYellow(fmt.Sprintf("%s: %s -> %s", d.Field, orNone(d.Old), orNone(d.New)))})
+				}
+			}
+		}
+	}
+	root := putils.TreeFromLeveledList(leveledList)
+	root.Text = pterm.NewStyle(pterm.FgRed).Sprintf("Spec diff %s -> %s", diff.From, diff.To)
+	return pterm.DefaultTree.WithRoot(root).Render()
+}
+
+// diffLine formats one changed service or member, e.g. "~ brightness (piid:3)"
+func diffLine(change, id, name, oldName string, oldIid, newIid int) string {
+	switch change {
+	case miservice.SpecAdded:
+		return pterm.Green(fmt.Sprintf("+ %s (%s:%d)", name, id, newIid))
+	case miservice.SpecRemoved:
+		return pterm.Red(fmt.Sprintf("- %s (%s:%d)", name, id, oldIid))
+	case miservice.SpecMoved:
+		return pterm.Yellow(fmt.Sprintf("> %s (%s:%d -> %d)", name, id, oldIid, newIid))
+	case miservice.SpecRenamed:
+		return pterm.Cyan(fmt.Sprintf("* %s -> %s (%s:%d)", oldName, name, id, newIid))
+	}
+	return fmt.Sprintf("~ %s (%s:%d)", name, id, newIid)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
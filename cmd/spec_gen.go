@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"micli/pkg/specgen"
+	"micli/pkg/util"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	specGenLang    string
+	specGenOut     string
+	specGenPackage string
+	specGenCmd     = &cobra.Command{
+		Use:   "gen <model|type_urn>",
+		Short: "Generate a typed client from a MIoT spec",
+		Long: `Generate a typed client from a MIoT spec.
+The generated package has one type per service with getters and setters for
+its properties and a method per action, value lists become enum types and
+value ranges are checked before a write. Requests go through IOService.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if specGenLang != "go" {
+				return fmt.Errorf("unsupported language %q, only go is supported", specGenLang)
+			}
+			data, err := ioSrv.MiotSpec(args[0])
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			err = specgen.Generate(&buf, data, specgen.Options{Package: specGenPackage, Model: args[0]})
+			if err != nil {
+				return err
+			}
+			if specGenOut == "" || specGenOut == "-" {
+				_, err = os.Stdout.Write(buf.Bytes())
+				return err
+			}
+			f, err := util.CreatNestedFile(specGenOut)
+			if err != nil {
+				return err
+			}
+			defer func(f *os.File) {
+				_ = f.Close()
+			}(f)
+			if _, err = f.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			pterm.Success.Printf("Generated %s\n", specGenOut)
+			return nil
+		},
+	}
+)
+
+func init() {
+	specGenCmd.Flags().StringVar(&specGenLang, "lang", "go", "language of the generated client")
+	specGenCmd.Flags().StringVar(&specGenOut, "out", "", "output file, stdout when empty")
+	specGenCmd.Flags().StringVar(&specGenPackage, "package", "", "package name, derived from the model when empty")
+	specGenCmd.Example = "  spec gen --lang go yeelink.light.ceiling4\n  spec gen --out light/light.go --package light yeelink.light.ceiling4"
+}
@@ -19,7 +19,7 @@ var (
 		Use:   "tts",
 		Short: "Text To Speech",
 		Long:  `Use Microsoft Edge's online text-to-speech service WITHOUT needing Microsoft Edge or Windows or an API key`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				fp  string
 				err error
@@ -31,7 +31,7 @@ var (
 				var voiceList []*edgetts.Voice
 				voiceList, err = tts.LoadVoiceList()
 				if err != nil {
-					return
+					return err
 				}
 				choices := make([]string, len(voiceList))
 				for i, _voice := range voiceList {
@@ -39,6 +39,9 @@ var (
 					voiceMap[choice] = _voice.ShortName
 					choices[i] = choice
 				}
+				if err = conf.RequirePrompt("no voice given"); err != nil {
+					return err
+				}
 				choice, _ := pterm.DefaultInteractiveSelect.
 					WithDefaultText("Please select a voice").
 					WithOptions(choices).
@@ -50,8 +53,7 @@ var (
 
 			fp, err = tts.TextToMp3(text, voice)
 			if err != nil {
-				pterm.Error.Printf("TTS failed: %v\n", err)
-				return
+				return fmt.Errorf("TTS failed: %v", err)
 			}
 			client := req.C()
 			r := client.R()
@@ -59,11 +61,14 @@ var (
 			var resp *req.Response
 			resp, err = r.Put(fmt.Sprintf("%s/edge_tts.mp3", conf.Cfg.Section("file").Key("TRANSFER_SH").MustString("https://transfer.sh")))
 			if err != nil {
-				pterm.Error.Printf("Upload failed: %v\n", err)
-				return
+				return fmt.Errorf("upload failed: %v", err)
 			}
 			textUrl := resp.String()
-			pterm.Success.Println("tts url:", textUrl)
+			err = render(map[string]string{"url": textUrl}, func() error {
+				pterm.Success.Println("tts url:", textUrl)
+				return nil
+			})
+			return err
 		},
 	}
 )
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"micli/pkg/miservice"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	force bool
+	clamp bool
+)
+
+// addValidateFlags adds --force and --clamp to a command that writes to a device
+func addValidateFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&force, "force", false, "send values as given, skip the spec check")
+	cmd.Flags().BoolVar(&clamp, "clamp", false, "move out of range values to the nearest valid value instead of refusing them")
+}
+
+// loadDeviceSpec returns the spec used to check writes, nil when --force is set
+// or the spec can not be loaded, in which case values are sent unchecked
+func loadDeviceSpec(model string) *miservice.MiotSpecInstancesData {
+	if force {
+		return nil
+	}
+	spec, err := ioSrv.MiotSpec(model)
+	if err != nil {
+		pterm.Warning.Printf("Fail to load spec of %s, values are not checked: %v\n", model, err)
+		return nil
+	}
+	return spec
+}
+
+// validateProps checks and converts [siid, piid, value] writes against the spec
+func validateProps(spec *miservice.MiotSpecInstancesData, props [][]interface{}) error {
+	if spec == nil {
+		return nil
+	}
+	for _, prop := range props {
+		siid, piid := prop[0].(int), prop[1].(int)
+		p := spec.Property(siid, piid)
+		if p == nil {
+			return fmt.Errorf("property %d-%d does not exist", siid, piid)
+		}
+		v, err := p.CheckWrite(prop[2], clamp)
+		if err != nil {
+			return fmt.Errorf("%d-%d: %w", siid, piid, err)
+		}
+		if fmt.Sprint(v) != fmt.Sprint(prop[2]) {
+			pterm.Debug.Printf("%d-%d: %v -> %v\n", siid, piid, prop[2], v)
+		}
+		prop[2] = v
+	}
+	return nil
+}
+
+// validateAction checks and converts the in arguments of action siid-aiid against the spec
+func validateAction(spec *miservice.MiotSpecInstancesData, siid, aiid int, args []interface{}) ([]interface{}, error) {
+	if spec == nil {
+		return args, nil
+	}
+	s := spec.Service(siid)
+	if s == nil {
+		return nil, fmt.Errorf("service %d does not exist", siid)
+	}
+	return s.ActionArgs(aiid, args, clamp)
+}
+
+// splitArgs accepts items both as separate arguments and comma separated
+func splitArgs(args []string) []string {
+	var items []string
+	for _, arg := range args {
+		for _, item := range strings.Split(arg, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
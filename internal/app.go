@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"micli/internal/handlers"
 	"micli/internal/middleware"
 	"micli/internal/static"
 
@@ -31,7 +32,14 @@ func (a *App) RegisterRoutes() {
 			"message": "ok",
 		})
 	})
+	a.RegisterLoginRoutes()
 	static.Static(web, func(handlers ...gin.HandlerFunc) {
 		a.NoRoute(handlers...)
 	})
 }
+
+// RegisterLoginRoutes serves the pending login QR code on /login
+func (a *App) RegisterLoginRoutes() {
+	a.GET("/login", handlers.LoginPage)
+	a.GET("/login/qr.png", handlers.LoginQR)
+}
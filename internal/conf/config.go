@@ -12,6 +12,8 @@ import (
 const DefaultConf = `# MiService Config
 [app]
 DEBUG = false
+# profile used when --profile is not given, empty for [account]
+PROFILE = ""
 
 [account]
 MI_USER = ""
@@ -28,6 +30,27 @@ DID = ""
 
 [file]
 TRANSFER_SH = "https://transfer.sh"
+
+# MIoT spec cache, CACHE_DIR defaults to the user cache dir
+[spec]
+CACHE_DIR = ""
+TTL = 168h
+
+# Where login tokens are kept: file, encrypted (AES-GCM, passphrase from
+# $MICLI_PASSPHRASE or a prompt), keyring (Secret Service / macOS keychain)
+# or env (read-only, token JSON in $MI_TOKEN). PATH is the file of file and encrypted.
+# MI_PASS may be "keyring:", "env:NAME" or "encrypted:<data>" to keep it out of this file.
+# Long-running commands renew service tokens older than REFRESH_AGE in the background.
+[token]
+STORE = file
+PATH = ""
+REFRESH_AGE = 12h
+
+# More accounts go into [profile.<name>] sections with the keys of [account],
+# see "micli profile add"
+
+# Per device transport, <did> = local|cloud
+[route]
 `
 
 var (
@@ -94,10 +117,20 @@ func Reset() {
 func Complete() (err error) {
 	var name, pass, region string
 	needSave := false
-	name = Cfg.Section("account").Key("MI_USER").MustString("")
-	pass = Cfg.Section("account").Key("MI_PASS").MustString("")
-	region = Cfg.Section("account").Key("REGION").MustString("")
+	name = Account().Key("MI_USER").MustString("")
+	pass = Account().Key("MI_PASS").MustString("")
+	region = Account().Key("REGION").MustString("")
 	if name == "" || pass == "" || region == "" {
+		// without a prompt MI_PASS may stay empty, a token from "auth login" does the job
+		if !CanPrompt() {
+			if name == "" {
+				return RequirePrompt("MI_USER is not set in [%s] of %s", Account().Name(), ConfPath)
+			}
+			if region == "" {
+				return RequirePrompt("REGION is not set in [%s] of %s", Account().Name(), ConfPath)
+			}
+			return nil
+		}
 		pterm.Warning.Println("Please complete your account information")
 	}
 	if name == "" {
@@ -107,7 +140,7 @@ func Complete() (err error) {
 			pterm.Error.Printf("Fail to get your account username: %v", err)
 			return
 		}
-		Cfg.Section("account").Key("MI_USER").SetValue(name)
+		Account().Key("MI_USER").SetValue(name)
 	}
 	if pass == "" {
 		needSave = true
@@ -116,7 +149,10 @@ func Complete() (err error) {
 			pterm.Error.Printf("Fail to get your account password: %v", err)
 			return
 		}
-		Cfg.Section("account").Key("MI_PASS").SetValue(pass)
+		if err = savePassword(pass); err != nil {
+			pterm.Error.Printf("Fail to save your account password: %v", err)
+			return
+		}
 	}
 	if region == "" {
 		needSave = true
@@ -140,7 +176,7 @@ func Complete() (err error) {
 			return
 		}
 		region = regionMap[regionI]
-		Cfg.Section("account").Key("REGION").SetValue(region)
+		Account().Key("REGION").SetValue(region)
 
 	}
 	if needSave {
@@ -160,7 +196,7 @@ func Complete() (err error) {
 }
 
 func SetDefaultDid(did string) (err error) {
-	Cfg.Section("account").Key("MI_DID").SetValue(did)
+	Account().Key("MI_DID").SetValue(did)
 	err = Cfg.SaveTo(ConfPath)
 	if err != nil {
 		pterm.Error.Printf("Fail to write config file: %v", err)
@@ -0,0 +1,30 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Interactive is cleared by --no-interactive, every prompt then fails instead
+var Interactive = true
+
+// ErrNoInteractive is returned where input is needed but can not be asked for
+var ErrNoInteractive = errors.New("input required")
+
+// CanPrompt reports whether the user can be asked, prompts on a closed or
+// redirected stdin would never get an answer
+func CanPrompt() bool {
+	return Interactive && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// RequirePrompt returns nil when the user can be asked, otherwise an
+// ErrNoInteractive error saying what is missing
+func RequirePrompt(format string, a ...any) error {
+	if CanPrompt() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNoInteractive, fmt.Sprintf(format, a...))
+}
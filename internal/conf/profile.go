@@ -0,0 +1,137 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"micli/pkg/secret"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/ini.v1"
+)
+
+// DefaultProfile names the [account] section
+const DefaultProfile = "default"
+
+const profilePrefix = "profile."
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Profile is the active profile, empty for the [account] section
+var Profile string
+
+// Account returns the account section of the active profile
+func Account() *ini.Section {
+	if Profile == "" {
+		return Cfg.Section("account")
+	}
+	return Cfg.Section(profilePrefix + Profile)
+}
+
+// Profiles returns the names of the [profile.<name>] sections
+func Profiles() []string {
+	var names []string
+	for _, s := range Cfg.Sections() {
+		if name, ok := strings.CutPrefix(s.Name(), profilePrefix); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ProfileSection returns the section of a profile, nil when it does not exist
+func ProfileSection(name string) *ini.Section {
+	if name == "" || name == DefaultProfile {
+		return Cfg.Section("account")
+	}
+	s, err := Cfg.GetSection(profilePrefix + name)
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// SelectProfile makes name, or [app] PROFILE when name is empty, the active profile
+func SelectProfile(name string) error {
+	if name == "" {
+		name = Cfg.Section("app").Key("PROFILE").MustString("")
+	}
+	if name == DefaultProfile {
+		name = ""
+	}
+	if name != "" && ProfileSection(name) == nil {
+		return fmt.Errorf("profile %q not found, see `profile list`", name)
+	}
+	Profile = name
+	return nil
+}
+
+// AddProfile creates the [profile.<name>] section and saves the config
+func AddProfile(name, user, region string) error {
+	if err := CheckProfileName(name); err != nil {
+		return err
+	}
+	if ProfileSection(name) != nil {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	s, err := Cfg.NewSection(profilePrefix + name)
+	if err != nil {
+		return err
+	}
+	for _, kv := range [][2]string{{"MI_USER", user}, {"MI_PASS", ""}, {"MI_DID", ""}, {"REGION", region}} {
+		s.Key(kv[0]).SetValue(kv[1])
+	}
+	return Cfg.SaveTo(ConfPath)
+}
+
+// RemoveProfile deletes a profile section, the default profile is used again
+// when it was the one in use
+func RemoveProfile(name string) error {
+	if name == DefaultProfile || ProfileSection(name) == nil {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	// "keyring:" keeps the password under the account of the profile, a named
+	// account may be shared and stays
+	if ProfileSection(name).Key("MI_PASS").String() == "keyring:" {
+		err := secret.Delete(KeyringService, keyringPassAccount+"."+name)
+		if err != nil && !errors.Is(err, secret.ErrNotFound) {
+			pterm.Warning.Printf("Fail to remove the password of %s from the keyring: %v\n", name, err)
+		}
+	}
+	Cfg.DeleteSection(profilePrefix + name)
+	if Cfg.Section("app").Key("PROFILE").String() == name {
+		Cfg.Section("app").Key("PROFILE").SetValue("")
+	}
+	return Cfg.SaveTo(ConfPath)
+}
+
+// UseProfile saves name as the profile used when --profile is not given
+func UseProfile(name string) error {
+	if ProfileSection(name) == nil {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	if name == DefaultProfile {
+		name = ""
+	}
+	Cfg.Section("app").Key("PROFILE").SetValue(name)
+	return Cfg.SaveTo(ConfPath)
+}
+
+// CheckProfileName refuses names that can not be used in file names
+func CheckProfileName(name string) error {
+	if name == DefaultProfile || !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// ProfileSuffix returns "" for the default profile and ".<name>" otherwise,
+// it keeps the token and cache names of profiles apart
+func ProfileSuffix() string {
+	if Profile == "" {
+		return ""
+	}
+	return "." + Profile
+}
@@ -0,0 +1,117 @@
+package conf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"micli/pkg/secret"
+
+	"github.com/pterm/pterm"
+)
+
+const (
+	// KeyringService is the keyring service name of the tokens and the password
+	KeyringService = "micli"
+	// PassphraseEnv holds the passphrase of the encrypted backend
+	PassphraseEnv = "MICLI_PASSPHRASE"
+	// TokenEnv holds the token JSON for the env backend
+	TokenEnv = "MI_TOKEN"
+
+	keyringPassAccount = "password"
+)
+
+var (
+	passphraseOnce sync.Once
+	passphrase     string
+	passphraseErr  error
+)
+
+// TokenBackend returns [token] STORE: file, encrypted, keyring or env
+func TokenBackend() string {
+	return strings.ToLower(Cfg.Section("token").Key("STORE").MustString("file"))
+}
+
+// Passphrase returns $MICLI_PASSPHRASE, or asks for it once on a terminal
+func Passphrase() (string, error) {
+	passphraseOnce.Do(func() {
+		if passphrase = os.Getenv(PassphraseEnv); passphrase != "" {
+			return
+		}
+		if passphraseErr = RequirePrompt("no passphrase for the encrypted token store, set %s", PassphraseEnv); passphraseErr != nil {
+			return
+		}
+		passphrase, passphraseErr = pterm.DefaultInteractiveTextInput.WithMask("*").Show("Enter the passphrase of your micli secrets")
+		if passphraseErr == nil && passphrase == "" {
+			passphraseErr = errors.New("empty passphrase")
+		}
+	})
+	return passphrase, passphraseErr
+}
+
+// Password returns MI_PASS, resolving the references "keyring:[account]",
+// "env:[NAME]" and "encrypted:<data>" written by savePassword
+func Password() (string, error) {
+	pass := Account().Key("MI_PASS").MustString("")
+	scheme, ref, ok := strings.Cut(pass, ":")
+	if !ok {
+		return pass, nil
+	}
+	switch scheme {
+	case "keyring":
+		if ref == "" {
+			ref = keyringPassAccount + ProfileSuffix()
+		}
+		return secret.Get(KeyringService, ref)
+	case "env":
+		if ref == "" {
+			ref = "MI_PASS"
+		}
+		if v := os.Getenv(ref); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("MI_PASS refers to $%s which is not set", ref)
+	case "encrypted":
+		data, err := base64.StdEncoding.DecodeString(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid encrypted MI_PASS: %w", err)
+		}
+		key, err := Passphrase()
+		if err != nil {
+			return "", err
+		}
+		if data, err = secret.Open(key, data); err != nil {
+			return "", fmt.Errorf("decrypt MI_PASS: %w", err)
+		}
+		return string(data), nil
+	default:
+		return pass, nil
+	}
+}
+
+// savePassword sets MI_PASS, the keyring and encrypted backends keep the
+// password themselves and only a reference goes into the config
+func savePassword(pass string) error {
+	switch TokenBackend() {
+	case "keyring":
+		if err := secret.Set(KeyringService, keyringPassAccount+ProfileSuffix(), pass); err != nil {
+			return err
+		}
+		pass = "keyring:"
+	case "encrypted":
+		key, err := Passphrase()
+		if err != nil {
+			return err
+		}
+		data, err := secret.Seal(key, []byte(pass))
+		if err != nil {
+			return err
+		}
+		pass = "encrypted:" + base64.StdEncoding.EncodeToString(data)
+	}
+	Account().Key("MI_PASS").SetValue(pass)
+	return nil
+}
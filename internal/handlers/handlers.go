@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"net/http"
+	"sync"
+
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	loginQRMu sync.RWMutex
+	loginQR   []byte
+)
+
 // NotFound returns custom 404 page
 func NotFound(c *gin.Context) {
 	c.JSON(200, gin.H{
@@ -12,3 +20,37 @@ func NotFound(c *gin.Context) {
 		"data": "",
 	})
 }
+
+// SetLoginQR publishes the PNG of a pending login QR code, nil takes it down
+func SetLoginQR(png []byte) {
+	loginQRMu.Lock()
+	defer loginQRMu.Unlock()
+	loginQR = png
+}
+
+// LoginPage shows the pending login QR code and reloads until it is gone
+func LoginPage(c *gin.Context) {
+	loginQRMu.RLock()
+	pending := loginQR != nil
+	loginQRMu.RUnlock()
+	body := `<p>No login pending.</p>`
+	if pending {
+		body = `<p>Scan the code with the Mi Home app.</p><img src="/login/qr.png" alt="login QR code">`
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="5"><title>micli login</title></head>
+<body style="font-family:sans-serif;text-align:center">`+body+`</body></html>`))
+}
+
+// LoginQR serves the PNG of the pending login QR code
+func LoginQR(c *gin.Context) {
+	loginQRMu.RLock()
+	png := loginQR
+	loginQRMu.RUnlock()
+	if png == nil {
+		NotFound(c)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
@@ -0,0 +1,221 @@
+package miio
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// DefaultTimeout is how long a single request waits for the device to answer.
+const DefaultTimeout = 3 * time.Second
+
+// ErrTimeout is returned when the device does not answer in time.
+var ErrTimeout = errors.New("miio: device did not respond")
+
+// Error is an error returned by the device itself.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("miio error %d: %s", e.Code, e.Message)
+}
+
+type response struct {
+	ID     int                 `json:"id"`
+	Result jsoniter.RawMessage `json:"result"`
+	Error  *Error              `json:"error"`
+}
+
+// Client talks to a single device over the LAN miIO protocol.
+type Client struct {
+	addr    *net.UDPAddr
+	token   []byte
+	key     []byte
+	iv      []byte
+	timeout time.Duration
+
+	mu       sync.Mutex
+	deviceID uint32
+	stamp    uint32
+	stampAt  time.Time
+	id       int
+}
+
+// New creates a client for the device at ip. The token is the 32 character
+// hex string returned by the cloud device list.
+func New(ip, token string) (*Client, error) {
+	t, err := hex.DecodeString(token)
+	if err != nil || len(t) != 16 {
+		return nil, fmt.Errorf("invalid device token %q", token)
+	}
+	host := ip
+	if _, _, err = net.SplitHostPort(ip); err != nil {
+		host = net.JoinHostPort(ip, fmt.Sprint(Port))
+	}
+	addr, err := net.ResolveUDPAddr("udp4", host)
+	if err != nil {
+		return nil, err
+	}
+	key, iv := cipherKeys(t)
+	return &Client{
+		addr:    addr,
+		token:   t,
+		key:     key,
+		iv:      iv,
+		timeout: DefaultTimeout,
+		id:      int(time.Now().Unix() % 10000),
+	}, nil
+}
+
+// SetTimeout changes the per request timeout.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// Addr returns the device address.
+func (c *Client) Addr() *net.UDPAddr {
+	return c.addr
+}
+
+// Handshake sends the hello packet and records the device id and stamp.
+func (c *Client) Handshake() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.handshake()
+}
+
+func (c *Client) handshake() error {
+	pkt, err := c.roundTrip(HelloPacket())
+	if err != nil {
+		return err
+	}
+	h, err := ParseHeader(pkt)
+	if err != nil {
+		return err
+	}
+	c.deviceID = h.DeviceID
+	c.stamp = h.Stamp
+	c.stampAt = time.Now()
+	return nil
+}
+
+// Send calls a raw miIO method on the device and returns its result.
+func (c *Client) Send(method string, params interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if params == nil {
+		params = []interface{}{}
+	}
+	var (
+		res *response
+		err error
+	)
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.stampAt.IsZero() || attempt > 0 {
+			if err = c.handshake(); err != nil {
+				return nil, err
+			}
+		}
+		res, err = c.call(method, params)
+		if err == nil || !errors.Is(err, ErrTimeout) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	var result interface{}
+	if len(res.Result) > 0 {
+		if err = json.Unmarshal(res.Result, &result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) call(method string, params interface{}) (*response, error) {
+	c.id++
+	id := c.id
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stamp := c.stamp + uint32(time.Since(c.stampAt).Seconds())
+	pkt, err := encode(c.token, c.key, c.iv, c.deviceID, stamp, payload)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(pkt)
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := decode(c.token, c.key, c.iv, reply)
+	if err != nil {
+		return nil, err
+	}
+	var res response
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("invalid response from device: %w", err)
+	}
+	if res.ID != id {
+		return nil, fmt.Errorf("response id mismatch: sent %d, got %d", id, res.ID)
+	}
+	return &res, nil
+}
+
+func (c *Client) roundTrip(pkt []byte) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, c.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer func(conn *net.UDPConn) {
+		_ = conn.Close()
+	}(conn)
+	_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err = conn.Write(pkt); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return nil, fmt.Errorf("%w: %s", ErrTimeout, c.addr)
+		}
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// MiotRequest maps the cloud /miotspec commands (prop/get, prop/set, action)
+// to their miIO method names so the same params work on the LAN.
+func (c *Client) MiotRequest(cmd string, params interface{}) (interface{}, error) {
+	var method string
+	switch cmd {
+	case "prop/get":
+		method = "get_properties"
+	case "prop/set":
+		method = "set_properties"
+	case "action":
+		method = "action"
+	default:
+		return nil, fmt.Errorf("unsupported miot command: %s", cmd)
+	}
+	return c.Send(method, params)
+}
@@ -0,0 +1,201 @@
+package miio
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testToken    = "00112233445566778899aabbccddeeff"
+	testDeviceID = 0x1234abcd
+	testStamp    = 1000
+)
+
+// fakeDevice answers miIO packets on a loopback UDP socket. reply gets the
+// device and the decrypted request and returns the raw packet to send back,
+// nil sends nothing.
+type fakeDevice struct {
+	conn  *net.UDPConn
+	token []byte
+	key   []byte
+	iv    []byte
+	reply func(d *fakeDevice, req map[string]interface{}) []byte
+}
+
+func newFakeDevice(t *testing.T, reply func(d *fakeDevice, req map[string]interface{}) []byte) (*fakeDevice, *Client) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	token, _ := hex.DecodeString(testToken)
+	key, iv := cipherKeys(token)
+	d := &fakeDevice{conn: conn, token: token, key: key, iv: iv, reply: reply}
+	go d.serve()
+	c, err := New(conn.LocalAddr().String(), testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetTimeout(200 * time.Millisecond)
+	return d, c
+}
+
+func (d *fakeDevice) serve() {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		pkt := buf[:n]
+		var out []byte
+		if n == headerSize && binary.BigEndian.Uint32(pkt[4:8]) == 0xffffffff {
+			out = helloReply()
+		} else {
+			_, payload, err := decode(d.token, d.key, d.iv, pkt)
+			if err != nil {
+				continue
+			}
+			var req map[string]interface{}
+			if err = json.Unmarshal(payload, &req); err != nil {
+				continue
+			}
+			out = d.reply(d, req)
+		}
+		if out != nil {
+			_, _ = d.conn.WriteToUDP(out, from)
+		}
+	}
+}
+
+// answer encrypts payload into a reply packet
+func (d *fakeDevice) answer(payload string) []byte {
+	pkt, err := encode(d.token, d.key, d.iv, testDeviceID, testStamp, []byte(payload))
+	if err != nil {
+		panic(err)
+	}
+	return pkt
+}
+
+func helloReply() []byte {
+	pkt := make([]byte, headerSize)
+	binary.BigEndian.PutUint16(pkt[0:2], magic)
+	binary.BigEndian.PutUint16(pkt[2:4], headerSize)
+	binary.BigEndian.PutUint32(pkt[8:12], testDeviceID)
+	binary.BigEndian.PutUint32(pkt[12:16], testStamp)
+	return pkt
+}
+
+func TestHandshake(t *testing.T) {
+	_, c := newFakeDevice(t, nil)
+	if err := c.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if c.deviceID != testDeviceID || c.stamp != testStamp {
+		t.Fatalf("got device %#x stamp %d", c.deviceID, c.stamp)
+	}
+}
+
+func TestDeviceError(t *testing.T) {
+	_, c := newFakeDevice(t, func(d *fakeDevice, req map[string]interface{}) []byte {
+		return d.answer(`{"id":` + itoa(int(req["id"].(float64))) + `,"error":{"code":-9999,"message":"unknown method"}}`)
+	})
+	_, err := c.Send("miIO.info", nil)
+	var devErr *Error
+	if !errors.As(err, &devErr) || devErr.Code != -9999 {
+		t.Fatalf("got %v, want device error -9999", err)
+	}
+}
+
+func TestMalformedReplies(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply func(d *fakeDevice, id int) []byte
+		want  string
+	}{
+		{
+			name: "header length under 32 bytes",
+			reply: func(d *fakeDevice, id int) []byte {
+				pkt := make([]byte, 48)
+				binary.BigEndian.PutUint16(pkt[0:2], magic)
+				binary.BigEndian.PutUint16(pkt[2:4], 16)
+				return pkt
+			},
+			want: "invalid packet length",
+		},
+		{
+			name:  "short packet",
+			reply: func(d *fakeDevice, id int) []byte { return []byte{0x21, 0x31, 0x00} },
+			want:  "packet too short",
+		},
+		{
+			name: "bad magic",
+			reply: func(d *fakeDevice, id int) []byte {
+				return make([]byte, headerSize)
+			},
+			want: "bad magic",
+		},
+		{
+			name: "truncated packet",
+			reply: func(d *fakeDevice, id int) []byte {
+				pkt := d.answer(`{"id":` + itoa(id) + `,"result":["ok"]}`)
+				return pkt[:len(pkt)-8]
+			},
+			want: "truncated packet",
+		},
+		{
+			name: "null payload",
+			reply: func(d *fakeDevice, id int) []byte {
+				return d.answer(`null`)
+			},
+			want: "response id mismatch",
+		},
+		{
+			name: "not json",
+			reply: func(d *fakeDevice, id int) []byte {
+				return d.answer(`{"id":`)
+			},
+			want: "invalid response from device",
+		},
+		{
+			name: "wrong token",
+			reply: func(d *fakeDevice, id int) []byte {
+				other, _ := hex.DecodeString(strings.Repeat("ab", 16))
+				key, iv := cipherKeys(other)
+				pkt, _ := encode(other, key, iv, testDeviceID, testStamp, []byte(`{"id":`+itoa(id)+`}`))
+				return pkt
+			},
+			want: "checksum mismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, c := newFakeDevice(t, func(d *fakeDevice, req map[string]interface{}) []byte {
+				return tt.reply(d, int(req["id"].(float64)))
+			})
+			_, err := c.Send("get_properties", nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	_, c := newFakeDevice(t, func(*fakeDevice, map[string]interface{}) []byte { return nil })
+	_, err := c.Send("get_properties", nil)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+}
+
+func itoa(i int) string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
@@ -0,0 +1,207 @@
+package miio
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	SourceHello = "hello"
+	SourceMDNS  = "mdns"
+
+	mdnsService = "_miio._udp.local."
+)
+
+var (
+	mdnsAddr      = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	mdnsDidRegexp = regexp.MustCompile(`_miio(\d+)`)
+)
+
+// Reply is a device found on the LAN.
+type Reply struct {
+	Did    string
+	IP     string
+	Stamp  uint32
+	Source string
+}
+
+// Discover broadcasts the hello packet and collects every answer until timeout.
+func Discover(timeout time.Duration) ([]*Reply, error) {
+	return hello(&net.UDPAddr{IP: net.IPv4bcast, Port: Port}, timeout, false)
+}
+
+// Hello sends the hello packet to a single address and returns its answer.
+func Hello(ip string, timeout time.Duration) (*Reply, error) {
+	replies, err := hello(&net.UDPAddr{IP: net.ParseIP(ip), Port: Port}, timeout, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(replies) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTimeout, ip)
+	}
+	return replies[0], nil
+}
+
+func hello(addr *net.UDPAddr, timeout time.Duration, single bool) ([]*Reply, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func(conn *net.UDPConn) {
+		_ = conn.Close()
+	}(conn)
+	if _, err = conn.WriteToUDP(HelloPacket(), addr); err != nil {
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	seen := make(map[string]*Reply)
+	var replies []*Reply
+	buf := make([]byte, 1024)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		h, err := ParseHeader(buf[:n])
+		if err != nil || h.DeviceID == 0 {
+			continue
+		}
+		did := strconv.FormatUint(uint64(h.DeviceID), 10)
+		if _, ok := seen[did]; ok {
+			continue
+		}
+		r := &Reply{Did: did, IP: from.IP.String(), Stamp: h.Stamp, Source: SourceHello}
+		seen[did] = r
+		replies = append(replies, r)
+		if single {
+			break
+		}
+	}
+	return replies, nil
+}
+
+// DiscoverMDNS queries _miio._udp.local and collects every announcement until timeout.
+// The did is taken from the instance name, e.g. "zhimi-airpurifier-v6_miio12345678".
+func DiscoverMDNS(timeout time.Duration) ([]*Reply, error) {
+	name, err := dnsmessage.NewName(mdnsService)
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	if err = b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err = b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func(conn *net.UDPConn) {
+		_ = conn.Close()
+	}(conn)
+	if _, err = conn.WriteToUDP(query, mdnsAddr); err != nil {
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	seen := make(map[string]*Reply)
+	var replies []*Reply
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		for _, r := range parseMDNS(buf[:n], from.IP) {
+			if _, ok := seen[r.Did]; ok {
+				continue
+			}
+			seen[r.Did] = r
+			replies = append(replies, r)
+		}
+	}
+	return replies, nil
+}
+
+// parseMDNS extracts miio devices from an mDNS response, preferring the A
+// record of the announced host over the source address of the packet.
+func parseMDNS(msg []byte, from net.IP) []*Reply {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return nil
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil
+	}
+	hosts := make(map[string]string)
+	addrs := make(map[string]string)
+	var instances []string
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			break
+		}
+		instances, _ = collectRecord(&p, h, p.SkipAnswer, instances, hosts, addrs)
+	}
+	if err := p.SkipAllAuthorities(); err == nil {
+		for {
+			h, err := p.AdditionalHeader()
+			if err != nil {
+				break
+			}
+			instances, _ = collectRecord(&p, h, p.SkipAdditional, instances, hosts, addrs)
+		}
+	}
+	var replies []*Reply
+	for _, instance := range instances {
+		m := mdnsDidRegexp.FindStringSubmatch(instance)
+		if m == nil {
+			continue
+		}
+		ip := from.String()
+		if a, ok := addrs[hosts[instance]]; ok {
+			ip = a
+		}
+		replies = append(replies, &Reply{Did: m[1], IP: ip, Source: SourceMDNS})
+	}
+	return replies
+}
+
+func collectRecord(p *dnsmessage.Parser, h dnsmessage.ResourceHeader, skip func() error, instances []string, hosts, addrs map[string]string) ([]string, error) {
+	switch h.Type {
+	case dnsmessage.TypePTR:
+		r, err := p.PTRResource()
+		if err != nil {
+			return instances, err
+		}
+		if h.Name.String() == mdnsService {
+			instances = append(instances, r.PTR.String())
+		}
+	case dnsmessage.TypeSRV:
+		r, err := p.SRVResource()
+		if err != nil {
+			return instances, err
+		}
+		hosts[h.Name.String()] = r.Target.String()
+	case dnsmessage.TypeA:
+		r, err := p.AResource()
+		if err != nil {
+			return instances, err
+		}
+		addrs[h.Name.String()] = net.IP(r.A[:]).String()
+	default:
+		return instances, skip()
+	}
+	return instances, nil
+}
@@ -0,0 +1,19 @@
+package miio
+
+import (
+	"fmt"
+	"testing"
+)
+
+// FakeDevice starts a fake device for the tests of package miio_test and
+// returns its address and token. reply gets the method and the JSON params of
+// each request and returns the members of the answer that follow "id", e.g.
+// `"result":[...]`.
+func FakeDevice(t *testing.T, reply func(method, params string) string) (addr, token string) {
+	d, _ := newFakeDevice(t, func(d *fakeDevice, req map[string]interface{}) []byte {
+		params, _ := json.Marshal(req["params"])
+		method, _ := req["method"].(string)
+		return d.answer(fmt.Sprintf(`{"id":%d,%s}`, int(req["id"].(float64)), reply(method, string(params))))
+	})
+	return d.conn.LocalAddr().String(), testToken
+}
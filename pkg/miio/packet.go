@@ -0,0 +1,137 @@
+package miio
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Port is the UDP port every miIO device listens on.
+	Port = 54321
+
+	headerSize = 32
+	magic      = 0x2131
+)
+
+// Header is the fixed 32 byte header in front of every miIO packet.
+type Header struct {
+	Length   uint16
+	Unknown  uint32
+	DeviceID uint32
+	Stamp    uint32
+	Checksum [16]byte
+}
+
+// HelloPacket returns the handshake packet. Devices answer it with their
+// device id and current stamp, which must be echoed in later packets.
+func HelloPacket() []byte {
+	pkt := bytes.Repeat([]byte{0xff}, headerSize)
+	binary.BigEndian.PutUint16(pkt[0:2], magic)
+	binary.BigEndian.PutUint16(pkt[2:4], headerSize)
+	return pkt
+}
+
+// ParseHeader decodes the header of a raw packet.
+func ParseHeader(pkt []byte) (*Header, error) {
+	if len(pkt) < headerSize {
+		return nil, fmt.Errorf("packet too short: %d bytes", len(pkt))
+	}
+	if binary.BigEndian.Uint16(pkt[0:2]) != magic {
+		return nil, fmt.Errorf("bad magic: %#04x", binary.BigEndian.Uint16(pkt[0:2]))
+	}
+	h := &Header{
+		Length:   binary.BigEndian.Uint16(pkt[2:4]),
+		Unknown:  binary.BigEndian.Uint32(pkt[4:8]),
+		DeviceID: binary.BigEndian.Uint32(pkt[8:12]),
+		Stamp:    binary.BigEndian.Uint32(pkt[12:16]),
+	}
+	copy(h.Checksum[:], pkt[16:32])
+	if h.Length < headerSize {
+		return nil, fmt.Errorf("invalid packet length %d in header", h.Length)
+	}
+	if int(h.Length) > len(pkt) {
+		return nil, fmt.Errorf("truncated packet: header says %d bytes, got %d", h.Length, len(pkt))
+	}
+	return h, nil
+}
+
+// cipherKeys derives the AES key and iv from a device token.
+func cipherKeys(token []byte) (key, iv []byte) {
+	k := md5.Sum(token)
+	v := md5.Sum(append(k[:], token...))
+	return k[:], v[:]
+}
+
+func encrypt(key, iv, plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return out, nil
+}
+
+func decrypt(key, iv, data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted payload length %d", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, errors.New("invalid padding, is the token correct?")
+	}
+	return out[:len(out)-pad], nil
+}
+
+// encode builds an encrypted packet for the given payload.
+func encode(token, key, iv []byte, deviceID, stamp uint32, payload []byte) ([]byte, error) {
+	data, err := encrypt(key, iv, payload)
+	if err != nil {
+		return nil, err
+	}
+	pkt := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint16(pkt[0:2], magic)
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+	binary.BigEndian.PutUint32(pkt[8:12], deviceID)
+	binary.BigEndian.PutUint32(pkt[12:16], stamp)
+	copy(pkt[16:32], token)
+	copy(pkt[32:], data)
+	sum := md5.Sum(pkt)
+	copy(pkt[16:32], sum[:])
+	return pkt, nil
+}
+
+// decode verifies the checksum of a packet and returns its decrypted payload.
+func decode(token, key, iv, pkt []byte) (*Header, []byte, error) {
+	h, err := ParseHeader(pkt)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkt = pkt[:h.Length]
+	if len(pkt) == headerSize {
+		return h, nil, nil
+	}
+	check := append([]byte{}, pkt...)
+	copy(check[16:32], token)
+	if md5.Sum(check) != h.Checksum {
+		return nil, nil, errors.New("checksum mismatch, is the token correct?")
+	}
+	payload, err := decrypt(key, iv, pkt[headerSize:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, bytes.TrimRight(payload, "\x00"), nil
+}
@@ -0,0 +1,95 @@
+package miio_test
+
+import (
+	"errors"
+	"testing"
+
+	"micli/pkg/miio"
+	"micli/pkg/miservice"
+)
+
+// newLocalIOService routes did 1 over the LAN to a fake device
+func newLocalIOService(t *testing.T, reply func(method, params string) string) *miservice.IOService {
+	t.Helper()
+	addr, token := miio.FakeDevice(t, reply)
+	io := miservice.NewIOService(miservice.New("user", "pass", "cn", nil))
+	io.SetRoute("1", miservice.NewLocalTransport(func(did string) (string, string, error) {
+		return addr, token, nil
+	}))
+	return io
+}
+
+func TestLocalGetSetProperties(t *testing.T) {
+	sent := make(chan string, 1)
+	io := newLocalIOService(t, func(method, params string) string {
+		sent <- method + " " + params
+		switch method {
+		case "get_properties":
+			return `"result":[{"did":"1","siid":2,"piid":1,"code":0,"value":true},{"did":"1","siid":2,"piid":2,"code":-4001}]`
+		case "set_properties":
+			return `"result":[{"did":"1","siid":2,"piid":1,"code":0}]`
+		}
+		return `"error":{"code":-9999,"message":"unknown method"}`
+	})
+
+	got, err := io.MiotGetProps("1", [][]interface{}{{2, 1}, {2, 2}, {2, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-sent, `get_properties [{"did":"1","piid":1,"siid":2},{"did":"1","piid":2,"siid":2},{"did":"1","piid":5,"siid":2}]`; got != want {
+		t.Fatalf("sent %s, want %s", got, want)
+	}
+	if len(got) != 3 || got[0].Value != true || got[0].Err() != nil {
+		t.Fatalf("got %+v", got)
+	}
+	var miotErr *miservice.MiotError
+	if !errors.As(got[1].Err(), &miotErr) || miotErr.Code != -4001 || got[1].Value != nil {
+		t.Fatalf("got %+v, want code -4001", got[1])
+	}
+	// the device left 2-5 out of its answer
+	if got[2].Code != miservice.MiotCodeNoResult || got[2].Reason != "no result returned" {
+		t.Fatalf("got %+v, want no result", got[2])
+	}
+
+	set, err := io.MiotSetProps("1", [][]interface{}{{2, 1, false}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-sent, `set_properties [{"did":"1","piid":1,"siid":2,"value":false}]`; got != want {
+		t.Fatalf("sent %s, want %s", got, want)
+	}
+	if len(set) != 1 || set[0].Code != 0 || set[0].Value != false {
+		t.Fatalf("got %+v", set)
+	}
+}
+
+func TestLocalAction(t *testing.T) {
+	sent := make(chan string, 1)
+	io := newLocalIOService(t, func(method, params string) string {
+		sent <- method + " " + params
+		return `"result":{"did":"1","siid":3,"aiid":1,"code":0,"out":[7]}`
+	})
+
+	res, err := io.MiotAction("1", []int{3, 1}, []interface{}{"on"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-sent, `action {"aiid":1,"did":"1","in":["on"],"siid":3}`; got != want {
+		t.Fatalf("sent %s, want %s", got, want)
+	}
+	if res.Code != 0 || len(res.Out) != 1 || res.Out[0] != float64(7) {
+		t.Fatalf("got %+v", res)
+	}
+}
+
+func TestLocalDeviceError(t *testing.T) {
+	io := newLocalIOService(t, func(method, params string) string {
+		return `"error":{"code":-5001,"message":"command error"}`
+	})
+
+	_, err := io.MiotGetProps("1", [][]interface{}{{2, 1}})
+	var devErr *miio.Error
+	if !errors.As(err, &devErr) || devErr.Code != -5001 {
+		t.Fatalf("got %v, want device error -5001", err)
+	}
+}
@@ -0,0 +1,82 @@
+package miservice
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrAuthExpired means the login was rejected or the session could not be renewed.
+	ErrAuthExpired = errors.New("auth expired")
+	// ErrDeviceOffline means the cloud or the LAN reports the device as unreachable.
+	ErrDeviceOffline = errors.New("device offline")
+	// ErrRateLimited means the server asked us to slow down.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrNetwork means the request never got an answer from the server.
+	ErrNetwork = errors.New("network error")
+	// ErrCaptchaRequired means a password login asks for a captcha nobody can answer.
+	ErrCaptchaRequired = errors.New("captcha required")
+	// ErrVerificationRequired means a password login asks for an SMS or email code nobody can enter.
+	ErrVerificationRequired = errors.New("identity verification required")
+)
+
+const (
+	// MiotCodeDeviceOffline is the MIoT result code of an unreachable device
+	MiotCodeDeviceOffline = -704042011
+	// MiotCodeNoResult marks a property left out of the answer of the device,
+	// it is not sent by devices
+	MiotCodeNoResult = -1
+)
+
+// APIError is a non-zero answer from the Xiaomi cloud.
+type APIError struct {
+	URI        string
+	StatusCode int
+	Code       int
+	Message    string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("error %s: %s", e.URI, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuthExpired
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// MiotError is a non-zero code in a prop/get, prop/set or action result.
+type MiotError struct {
+	Did  string
+	Siid int
+	Iid  int
+	Code int
+}
+
+func (e *MiotError) Error() string {
+	return fmt.Sprintf("miot error %d: %s (did:%s, siid:%d, iid:%d)", e.Code, MiotReason(e.Code), e.Did, e.Siid, e.Iid)
+}
+
+func (e *MiotError) Unwrap() error {
+	if e.Code == MiotCodeDeviceOffline {
+		return ErrDeviceOffline
+	}
+	return nil
+}
+
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package miservice
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pterm/pterm"
+)
+
+type RoomInfo struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Dids []string `json:"dids"`
+}
+
+type HomeInfo struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	UID   string      `json:"uid"`
+	Dids  []string    `json:"dids"`
+	Rooms []*RoomInfo `json:"rooms"`
+}
+
+// HomeList returns the homes of the account, including shared ones, with their rooms.
+// HomeInfo.Dids holds the devices that are not assigned to any room.
+func (s *IOService) HomeList() (homes []*HomeInfo, err error) {
+	data := map[string]interface{}{
+		"fg":              true,
+		"fetch_share":     true,
+		"fetch_share_dev": true,
+		"limit":           300,
+		"app_ver":         7,
+	}
+	var result interface{}
+	result, err = s.Request("/v2/homeroom/gethome", data)
+	if err != nil {
+		return nil, err
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected map[string]interface{} for gethome result, got %T", result)
+	}
+	list, _ := resultMap["homelist"].([]interface{})
+	if share, ok := resultMap["share_home_list"].([]interface{}); ok {
+		list = append(list, share...)
+	}
+	homes = make([]*HomeInfo, 0, len(list))
+	for _, item := range list {
+		home, ok := item.(map[string]interface{})
+		if !ok {
+			pterm.Warning.Printf("home item is not map[string]interface{}, got %T, skipping\n", item)
+			continue
+		}
+		info := &HomeInfo{
+			ID:   stringField(home, "id"),
+			Name: stringField(home, "name"),
+			UID:  stringField(home, "uid"),
+			Dids: stringList(home["dids"]),
+		}
+		rooms, _ := home["roomlist"].([]interface{})
+		for _, r := range rooms {
+			room, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			info.Rooms = append(info.Rooms, &RoomInfo{
+				ID:   stringField(room, "id"),
+				Name: stringField(room, "name"),
+				Dids: stringList(room["dids"]),
+			})
+		}
+		homes = append(homes, info)
+	}
+	return
+}
+
+// Room returns the room a device is assigned to, or nil.
+func (h *HomeInfo) Room(did string) *RoomInfo {
+	for _, room := range h.Rooms {
+		for _, d := range room.Dids {
+			if d == did {
+				return room
+			}
+		}
+	}
+	return nil
+}
+
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	res := make([]string, 0, len(list))
+	for _, item := range list {
+		switch s := item.(type) {
+		case string:
+			res = append(res, s)
+		case float64:
+			res = append(res, strconv.FormatFloat(s, 'f', -1, 64))
+		}
+	}
+	return res
+}
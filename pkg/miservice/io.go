@@ -8,12 +8,15 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"micli/pkg/util"
 
 	"github.com/pterm/pterm"
+	"github.com/samber/lo"
 )
 
 const (
@@ -21,25 +24,41 @@ const (
 )
 
 type IOService struct {
-	service *Service
-	baseUrl string
+	service   *Service
+	baseUrl   string
+	cloud     Transport
+	mu        sync.RWMutex
+	routes    map[string]Transport
+	specCache *SpecCache
+	// specMu serializes spec lookups so concurrent callers share one download
+	specMu sync.Mutex
 }
 
 type DeviceInfo struct {
-	Name  string `json:"name"`
-	Model string `json:"model"`
-	Did   string `json:"did"`
-	Token string `json:"token"`
+	Name      string                 `json:"name"`
+	Model     string                 `json:"model"`
+	Did       string                 `json:"did"`
+	Token     string                 `json:"token"`
+	IsOnline  bool                   `json:"isOnline"`
+	LocalIP   string                 `json:"localip,omitempty"`
+	Mac       string                 `json:"mac,omitempty"`
+	ParentID  string                 `json:"parent_id,omitempty"`
+	SSID      string                 `json:"ssid,omitempty"`
+	RSSI      int                    `json:"rssi,omitempty"`
+	FwVersion string                 `json:"fw_version,omitempty"`
+	Extra     map[string]interface{} `json:"extra,omitempty"`
+}
+
+type MiotSpecInstance struct {
+	Status  string `json:"status"`
+	Model   string `json:"model"`
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	Ts      int    `json:"ts"`
 }
 
 type MiotSpecInstances struct {
-	Instances []struct {
-		Status  string `json:"status"`
-		Model   string `json:"model"`
-		Version int    `json:"version"`
-		Type    string `json:"type"`
-		Ts      int    `json:"ts"`
-	} `json:"instances"`
+	Instances []*MiotSpecInstance `json:"instances"`
 }
 
 type MiotSpecInstancesData struct {
@@ -67,14 +86,15 @@ type MiotSpecProperty struct {
 		Description string `json:"description"`
 	} `json:"value-list,omitempty"`
 	ValueRange []interface{} `json:"value-range,omitempty"`
+	Unit       string        `json:"unit,omitempty"`
 }
 
 type MiotSpecAction struct {
-	Iid         int           `json:"iid"`
-	Type        string        `json:"type"`
-	Description string        `json:"description"`
-	In          []float64     `json:"in"`
-	Out         []interface{} `json:"out"`
+	Iid         int    `json:"iid"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	In          []int  `json:"in"`
+	Out         []int  `json:"out"`
 }
 
 func NewIOService(service *Service) *IOService {
@@ -84,7 +104,45 @@ func NewIOService(service *Service) *IOService {
 	if service.region != "" && service.region != "cn" {
 		base = fmt.Sprintf("%s://%s.%s", protocol, service.region, host)
 	}
-	return &IOService{service: service, baseUrl: base}
+	s := &IOService{service: service, baseUrl: base, routes: make(map[string]Transport), specCache: NewSpecCache("", 0)}
+	s.cloud = &CloudTransport{io: s}
+	return s
+}
+
+// SpecCache returns the cache used by MiotSpec.
+func (s *IOService) SpecCache() *SpecCache {
+	return s.specCache
+}
+
+// SetSpecCache replaces the cache used by MiotSpec.
+func (s *IOService) SetSpecCache(c *SpecCache) {
+	s.specCache = c
+}
+
+// Cloud returns the cloud transport.
+func (s *IOService) Cloud() Transport {
+	return s.cloud
+}
+
+// SetRoute sends MIoT requests for did through t, nil restores the cloud route.
+func (s *IOService) SetRoute(did string, t Transport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t == nil {
+		delete(s.routes, did)
+		return
+	}
+	s.routes[did] = t
+}
+
+// Route returns the transport used for did.
+func (s *IOService) Route(did string) Transport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if t, ok := s.routes[did]; ok {
+		return t
+	}
+	return s.cloud
 }
 
 func (s *IOService) Request(uri string, args map[string]interface{}) (interface{}, error) {
@@ -135,36 +193,57 @@ func (s *IOService) DeviceList() (devices []*DeviceInfo, err error) {
 			pterm.Warning.Printf("device item is not map[string]interface{}, got %T, skipping\n", item)
 			continue
 		}
-		name, ok := device["name"].(string)
-		if !ok {
-			pterm.Warning.Printf("device 'name' is not string, got %T, skipping\n", device["name"])
+		info := parseDeviceInfo(device)
+		if info.Did == "" {
+			pterm.Warning.Printf("device %q has no usable 'did' (%T), skipping\n", info.Name, device["did"])
 			continue
 		}
-		model, ok := device["model"].(string)
-		if !ok {
-			pterm.Warning.Printf("device 'model' is not string, got %T, skipping\n", device["model"])
-			continue
-		}
-		did, ok := device["did"].(string)
-		if !ok {
-			pterm.Warning.Printf("device 'did' is not string, got %T, skipping\n", device["did"])
-			continue
-		}
-		token, ok := device["token"].(string)
-		if !ok {
-			pterm.Warning.Printf("device 'token' is not string, got %T, skipping\n", device["token"])
-			continue
-		}
-		devices = append(devices, &DeviceInfo{
-			Name:  name,
-			Model: model,
-			Did:   did,
-			Token: token,
-		})
+		devices = append(devices, info)
 	}
 	return
 }
 
+// parseDeviceInfo keeps whatever fields of a device_list item have a usable
+// type, a malformed field is logged and left empty instead of dropping the device.
+func parseDeviceInfo(device map[string]interface{}) *DeviceInfo {
+	info := &DeviceInfo{
+		Name:     stringField(device, "name"),
+		Model:    stringField(device, "model"),
+		Did:      stringField(device, "did"),
+		Token:    stringField(device, "token"),
+		LocalIP:  stringField(device, "localip"),
+		Mac:      stringField(device, "mac"),
+		ParentID: stringField(device, "parent_id"),
+		SSID:     stringField(device, "ssid"),
+	}
+	if online, ok := device["isOnline"].(bool); ok {
+		info.IsOnline = online
+	}
+	if rssi, ok := device["rssi"].(float64); ok {
+		info.RSSI = int(rssi)
+	}
+	if extra, ok := device["extra"].(map[string]interface{}); ok {
+		info.Extra = extra
+		info.FwVersion = stringField(extra, "fw_version")
+	}
+	return info
+}
+
+// stringField reads a string field, numbers are formatted and other types are logged and ignored
+func stringField(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		pterm.Warning.Printf("field '%s' is not string, got %T, ignoring\n", key, v)
+		return ""
+	}
+}
+
 func (s *IOService) HomeRequest(did, method string, params interface{}) (interface{}, error) {
 	data := map[string]interface{}{
 		"id":        1,
@@ -222,7 +301,55 @@ func (s *IOService) MiotRequest(cmd string, params interface{}) (interface{}, er
 	return s.Request(fmt.Sprintf("/miotspec/%s", cmd), map[string]interface{}{"params": params})
 }
 
-func (s *IOService) MiotGetProps(did string, props [][]interface{}) ([]interface{}, error) {
+// MiotDeviceRequest sends a MIoT request through the transport routed for did,
+// falling back to the cloud when that transport cannot reach the device.
+func (s *IOService) MiotDeviceRequest(did, cmd string, params interface{}) (interface{}, error) {
+	t := s.Route(did)
+	result, err := t.MiotRequest(did, cmd, params)
+	if err != nil && t != s.cloud && errors.Is(err, ErrTransportUnavailable) {
+		pterm.Warning.Printf("%s transport failed for %s (%v), falling back to cloud\n", t.Name(), did, err)
+		return s.cloud.MiotRequest(did, cmd, params)
+	}
+	return result, err
+}
+
+// PropResult is the outcome of one property read or write.
+type PropResult struct {
+	Did    string      `json:"did"`
+	Siid   int         `json:"siid"`
+	Piid   int         `json:"piid"`
+	Value  interface{} `json:"value"`
+	Code   int         `json:"code"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// Err returns the MIoT error of a failed result, or nil.
+func (r *PropResult) Err() error {
+	if r.Code == 0 {
+		return nil
+	}
+	return &MiotError{Did: r.Did, Siid: r.Siid, Iid: r.Piid, Code: r.Code}
+}
+
+// ActionResult is the outcome of an action.
+type ActionResult struct {
+	Did    string        `json:"did"`
+	Siid   int           `json:"siid"`
+	Aiid   int           `json:"aiid"`
+	Code   int           `json:"code"`
+	Reason string        `json:"reason,omitempty"`
+	Out    []interface{} `json:"out,omitempty"`
+}
+
+// Err returns the MIoT error of a failed action, or nil.
+func (r *ActionResult) Err() error {
+	if r.Code == 0 {
+		return nil
+	}
+	return &MiotError{Did: r.Did, Siid: r.Siid, Iid: r.Aiid, Code: r.Code}
+}
+
+func (s *IOService) MiotGetProps(did string, props [][]interface{}) ([]*PropResult, error) {
 	params := make([]map[string]interface{}, len(props))
 	for i, prop := range props {
 		params[i] = map[string]interface{}{
@@ -231,29 +358,24 @@ func (s *IOService) MiotGetProps(did string, props [][]interface{}) ([]interface
 			"piid": prop[1],
 		}
 	}
-	result, err := s.MiotRequest("prop/get", params)
+	result, err := s.MiotDeviceRequest(did, "prop/get", params)
 	if err != nil {
 		return nil, err
 	}
-	resultList, ok := result.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("expected []interface{} for prop/get result, got %T", result)
+	results, err := propResults("prop/get", did, props, result)
+	if err != nil {
+		return nil, err
 	}
-	values := make([]interface{}, len(resultList))
-	for i, it := range resultList {
-		itm, ok := it.(map[string]interface{})
-		if !ok {
-			pterm.Warning.Printf("prop/get item %d is not map[string]interface{}, got %T\n", i, it)
-			values[i] = nil
-			continue
-		}
-		if code, ok := itm["code"].(float64); ok && code == 0 {
-			values[i] = itm["value"]
-		} else {
-			values[i] = nil
+	// a partial answer is still useful, only fail when nothing could be read
+	for _, r := range results {
+		if r.Code == 0 {
+			return results, nil
 		}
 	}
-	return values, nil
+	if len(results) > 0 {
+		return results, results[0].Err()
+	}
+	return results, nil
 }
 
 func (s *IOService) MiotGetProp(did string, prop []interface{}) (interface{}, error) {
@@ -261,10 +383,13 @@ func (s *IOService) MiotGetProp(did string, prop []interface{}) (interface{}, er
 	if err != nil {
 		return nil, err
 	}
-	return results[0], nil
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return results[0].Value, results[0].Err()
 }
 
-func (s *IOService) MiotSetProps(did string, props [][]interface{}) ([]float64, error) {
+func (s *IOService) MiotSetProps(did string, props [][]interface{}) ([]*PropResult, error) {
 	params := make([]map[string]interface{}, len(props))
 	index := 0
 	for _, prop := range props {
@@ -276,105 +401,163 @@ func (s *IOService) MiotSetProps(did string, props [][]interface{}) ([]float64,
 		}
 		index++
 	}
-	result, err := s.MiotRequest("prop/set", params)
+	result, err := s.MiotDeviceRequest(did, "prop/set", params)
 	if err != nil {
 		return nil, err
 	}
+	results, err := propResults("prop/set", did, props, result)
+	if err != nil {
+		return nil, err
+	}
+	// the answer of a write carries no value, report the one that was sent
+	for i, r := range results {
+		r.Value = props[i][2]
+	}
+	return results, nil
+}
+
+func (s *IOService) MiotSetProp(did string, prop []interface{}) (*PropResult, error) {
+	results, err := s.MiotSetProps(did, [][]interface{}{prop})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("empty prop/set result")
+	}
+	return results[0], results[0].Err()
+}
+
+// MiotPropsBatch is how many properties the batched calls send per request,
+// the cloud refuses larger prop/get and prop/set lists.
+const MiotPropsBatch = 20
+
+// MiotGetPropsBatched reads any number of properties, MiotPropsBatch at a
+// time. Properties the device refuses only set the code of their result, an
+// error is returned when a request itself fails.
+func (s *IOService) MiotGetPropsBatched(did string, props [][]interface{}) ([]*PropResult, error) {
+	results := make([]*PropResult, 0, len(props))
+	for _, chunk := range lo.Chunk(props, MiotPropsBatch) {
+		res, err := s.MiotGetProps(did, chunk)
+		if res == nil {
+			return results, err
+		}
+		results = append(results, res...)
+	}
+	return results, nil
+}
+
+// MiotSetPropsBatched writes any number of properties, see MiotGetPropsBatched.
+func (s *IOService) MiotSetPropsBatched(did string, props [][]interface{}) ([]*PropResult, error) {
+	results := make([]*PropResult, 0, len(props))
+	for _, chunk := range lo.Chunk(props, MiotPropsBatch) {
+		res, err := s.MiotSetProps(did, chunk)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res...)
+	}
+	return results, nil
+}
 
+// propResults decodes the list answer of prop/get and prop/set into one result
+// per requested property, in the order of props. Answers are matched by siid
+// and piid, properties the device did not describe get MiotCodeNoResult.
+func propResults(cmd, did string, props [][]interface{}, result interface{}) ([]*PropResult, error) {
 	resultList, ok := result.([]interface{})
 	if !ok {
-		return nil, fmt.Errorf("expected []interface{} for prop/set result, got %T", result)
+		return nil, fmt.Errorf("expected []interface{} for %s result, got %T", cmd, result)
+	}
+	results := make([]*PropResult, len(props))
+	index := make(map[[2]int]int, len(props))
+	for i, prop := range props {
+		r := &PropResult{Did: did, Siid: toInt(prop[0]), Piid: toInt(prop[1]), Code: MiotCodeNoResult}
+		r.Reason = MiotReason(r.Code)
+		results[i] = r
+		index[[2]int{r.Siid, r.Piid}] = i
 	}
-	codes := make([]float64, len(resultList))
 	for i, it := range resultList {
 		itm, ok := it.(map[string]interface{})
 		if !ok {
-			pterm.Warning.Printf("prop/set item %d is not map[string]interface{}, got %T\n", i, it)
-			codes[i] = -1
+			pterm.Warning.Printf("%s item %d is not map[string]interface{}, got %T\n", cmd, i, it)
+			continue
+		}
+		var r *PropResult
+		siid, sok := itm["siid"].(float64)
+		piid, pok := itm["piid"].(float64)
+		if sok && pok {
+			if j, found := index[[2]int{int(siid), int(piid)}]; found {
+				r = results[j]
+			}
+		} else if i < len(results) {
+			// an answer without ids can only be matched by position
+			r = results[i]
+		}
+		if r == nil {
+			pterm.Warning.Printf("%s item %d answers %v-%v which was not requested\n", cmd, i, itm["siid"], itm["piid"])
 			continue
 		}
 		code, ok := itm["code"].(float64)
 		if !ok {
-			pterm.Warning.Printf("prop/set item %d 'code' is not float64, got %T\n", i, itm["code"])
-			codes[i] = -1
+			pterm.Warning.Printf("%s item %d 'code' is not float64, got %T\n", cmd, i, itm["code"])
 			continue
 		}
-		codes[i] = code
+		r.Code, r.Reason = int(code), ""
+		if r.Code == 0 {
+			r.Value = itm["value"]
+		} else {
+			r.Reason = MiotReason(r.Code)
+		}
 	}
-	return codes, nil
+	return results, nil
 }
 
-func (s *IOService) MiotSetProp(did string, prop []interface{}) (float64, error) {
-	results, err := s.MiotSetProps(did, [][]interface{}{prop})
-	if err != nil {
-		return 0, err
+func (s *IOService) MiotAction(did string, iid []int, args []interface{}) (*ActionResult, error) {
+	if args == nil {
+		args = []interface{}{}
 	}
-	return results[0], nil
-}
-
-func (s *IOService) MiotAction(did string, iid []int, args []interface{}) (float64, error) {
 	pterm.Info.Println(map[string]interface{}{
 		"did":  did,
 		"siid": iid[0],
 		"aiid": iid[1],
 		"in":   args,
 	})
-	result, err := s.MiotRequest("action", map[string]interface{}{
+	result, err := s.MiotDeviceRequest(did, "action", map[string]interface{}{
 		"did":  did,
 		"siid": iid[0],
 		"aiid": iid[1],
 		"in":   args,
 	})
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
-		return -1, fmt.Errorf("expected map[string]interface{} for action result, got %T", result)
+		return nil, fmt.Errorf("expected map[string]interface{} for action result, got %T", result)
 	}
 	code, ok := resultMap["code"].(float64)
 	if !ok {
-		return -1, fmt.Errorf("expected float64 for action 'code', got %T", resultMap["code"])
+		return nil, fmt.Errorf("expected float64 for action 'code', got %T", resultMap["code"])
 	}
-	return code, nil
+	res := &ActionResult{Did: did, Siid: iid[0], Aiid: iid[1], Code: int(code)}
+	// the raw out values, see MiotSpecService.ActionOutputs to decode them
+	res.Out, _ = resultMap["out"].([]interface{})
+	if res.Code != 0 {
+		res.Reason = MiotReason(res.Code)
+	}
+	return res, res.Err()
 }
 
 func (s *IOService) MiotSpec(keyword string) (data *MiotSpecInstancesData, err error) {
+	s.specMu.Lock()
+	defer s.specMu.Unlock()
+	var model string
 	if keyword == "" || !strings.HasPrefix(keyword, "urn") {
-		// p := path.Join(os.TempDir(), "miot-spec.json")
-		p := "./data/miot-spec.json"
-		var specs map[string]string
-		specs, err = s.loadSpec(p)
+		var index *SpecIndex
+		index, err = s.specIndex(false)
 		if err != nil {
-			var rr *http.Response
-			rr, err = s.service.client.Get("https://miot-spec.org/miot-spec-v2/instances?status=all")
-			if err != nil {
-				return
-			}
-			defer func(Body io.ReadCloser) {
-				_ = Body.Close()
-			}(rr.Body)
-			var instanceSpec *MiotSpecInstances
-			err = json.NewDecoder(rr.Body).Decode(&instanceSpec)
-			if err != nil {
-				return
-			}
-			specs = make(map[string]string)
-			for _, v := range instanceSpec.Instances {
-				specs[v.Model] = v.Type
-			}
-			var f *os.File
-			// 创建初始配置文件
-			f, err = util.CreatNestedFile(p)
-			defer func(f *os.File) {
-				_ = f.Close()
-			}(f)
-			if err != nil {
-				return
-			}
-			_ = json.NewEncoder(f).Encode(specs)
+			return
 		}
-		specs = s.getSpec(keyword, specs)
+		specs := s.getSpec(keyword, index.Types())
 		if len(specs) != 1 {
 			instances := make([]string, 0, len(specs))
 			for _, v := range specs {
@@ -383,27 +566,89 @@ func (s *IOService) MiotSpec(keyword string) (data *MiotSpecInstancesData, err e
 			err = fmt.Errorf("found %d instances: %s", len(specs), strings.Join(instances, ", "))
 			return
 		}
-		for _, v := range specs {
-			keyword = v
+		for k, v := range specs {
+			model, keyword = k, v
 			break
 		}
 	}
-	u := fmt.Sprintf("https://miot-spec.org/miot-spec-v2/instance?type=%s", keyword)
-	var rs *http.Response
-	rs, err = s.service.client.Get(u)
+	var entry *SpecEntry
+	entry, err = s.specEntry(keyword, model, false)
 	if err != nil {
 		return
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(rs.Body)
+	return entry.Data, nil
+}
 
-	err = json.NewDecoder(rs.Body).Decode(&data)
+// RefreshSpecIndex downloads the model to type list again.
+func (s *IOService) RefreshSpecIndex() (*SpecIndex, error) {
+	return s.specIndex(true)
+}
+
+// RefreshSpec downloads the document of a type URN again.
+func (s *IOService) RefreshSpec(typ, model string) (*SpecEntry, error) {
+	return s.specEntry(typ, model, true)
+}
+
+// specIndex returns the cached instance list while it is fresh. A stale
+// cache is still used when miot-spec.org can not be reached.
+func (s *IOService) specIndex(refresh bool) (*SpecIndex, error) {
+	cached, cacheErr := s.specCache.Index()
+	if !refresh && cacheErr == nil && s.specCache.Fresh(cached.FetchedAt) {
+		return cached, nil
+	}
+	var instances *MiotSpecInstances
+	err := s.getSpecJSON("https://miot-spec.org/miot-spec-v2/instances?status=all", &instances)
 	if err != nil {
-		return
+		if cacheErr == nil {
+			pterm.Warning.Printf("Fail to refresh spec list, using cache from %s: %v\n", time.Unix(cached.FetchedAt, 0).Format(time.DateTime), err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	index := &SpecIndex{FetchedAt: time.Now().Unix(), Instances: instances.Instances}
+	if err = s.specCache.SaveIndex(index); err != nil {
+		pterm.Warning.Printf("Fail to write spec cache: %v\n", err)
 	}
+	return index, nil
+}
 
-	return
+// specEntry returns the cached document of a type while it is fresh, see specIndex.
+func (s *IOService) specEntry(typ, model string, refresh bool) (*SpecEntry, error) {
+	cached, cacheErr := s.specCache.Get(typ)
+	if !refresh && cacheErr == nil && s.specCache.Fresh(cached.FetchedAt) {
+		return cached, nil
+	}
+	var data *MiotSpecInstancesData
+	err := s.getSpecJSON(fmt.Sprintf("https://miot-spec.org/miot-spec-v2/instance?type=%s", typ), &data)
+	if err != nil {
+		if cacheErr == nil {
+			pterm.Warning.Printf("Fail to refresh spec %s, using cache from %s: %v\n", typ, time.Unix(cached.FetchedAt, 0).Format(time.DateTime), err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	if model == "" && cacheErr == nil {
+		model = cached.Model
+	}
+	entry := &SpecEntry{Type: typ, Model: model, Version: specVersion(typ), FetchedAt: time.Now().Unix(), Data: data}
+	if err = s.specCache.Put(entry); err != nil {
+		pterm.Warning.Printf("Fail to write spec cache: %v\n", err)
+	}
+	return entry, nil
+}
+
+func (s *IOService) getSpecJSON(u string, v interface{}) error {
+	rs, err := s.service.client.Get(u)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNetwork, err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(rs.Body)
+	if rs.StatusCode != http.StatusOK {
+		return fmt.Errorf("error %s: %s", u, rs.Status)
+	}
+	return json.NewDecoder(rs.Body).Decode(v)
 }
 
 func (s *IOService) MiotDecode(ssecurity string, nonce string, data string, gzip bool) (interface{}, error) {
@@ -457,23 +702,3 @@ func (s *IOService) getSpec(keyword string, specs map[string]string) map[string]
 	}
 	return ret
 }
-
-func (s *IOService) loadSpec(p string) (map[string]string, error) {
-	if !util.Exists(p) {
-		return nil, errors.New("spec file not found")
-	}
-	f, err := os.Open(p)
-	if err != nil {
-		return nil, err
-	}
-	defer func(f *os.File) {
-		_ = f.Close()
-	}(f)
-	j := json.NewDecoder(f)
-	var specs map[string]string
-	err = j.Decode(&specs)
-	if err != nil {
-		return nil, err
-	}
-	return specs, nil
-}
@@ -0,0 +1,56 @@
+package miservice
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// miotCodes are the result codes documented by the MIoT spec. Extended codes
+// look like -70SSSDDDD where SSS is an HTTP-like status and DDDD the detail.
+var miotCodes = map[int]string{
+	0:     "success",
+	1:     "accepted, still executing",
+	-4000: "unknown error",
+	-4001: "property is not readable",
+	-4002: "property is not writable",
+	-4003: "property, action or event does not exist",
+	-4004: "other internal error",
+	-4005: "property value error",
+	-4006: "action in parameters error",
+	-4007: "did error",
+
+	-704040002: "service does not exist",
+	-704040003: "property does not exist",
+	-704040004: "event does not exist",
+	-704040005: "action does not exist",
+	-704042001: "device does not exist",
+	-704042011: "device offline",
+	-704053036: "device operation timeout",
+	-704083036: "operation timeout",
+	-704090001: "device does not exist",
+	-704220008: "invalid id",
+	-704220025: "action parameter count mismatch",
+	-704220035: "action parameter error",
+	-704220043: "property value error",
+	-704222034: "action return value error",
+	-705004000: "unknown error",
+	-705201013: "property is not readable",
+	-705201015: "action execution error",
+
+	// not from the spec, see propResults
+	MiotCodeNoResult: "no result returned",
+}
+
+// MiotReason describes a MIoT result code.
+func MiotReason(code int) string {
+	if reason, ok := miotCodes[code]; ok {
+		return reason
+	}
+	if code <= -700000000 && code > -710000000 {
+		status := (-code / 10000) % 1000
+		if text := http.StatusText(status); text != "" {
+			return fmt.Sprintf("%s (%d)", text, status)
+		}
+	}
+	return "unknown error"
+}
@@ -20,6 +20,23 @@ import (
 	"github.com/pterm/pterm"
 )
 
+// QRHandler shows the login QR code to the user, loginURL is the content of
+// the code and imageURL a picture of it hosted by Xiaomi
+type QRHandler func(loginURL, imageURL string) error
+
+// TerminalQR prints the QR code to stdout, it is the default QRHandler
+func TerminalQR(loginURL, imageURL string) error {
+	pterm.Info.Println("Please scan the QR code below using the Mi Home app:")
+	qrterminal.GenerateHalfBlock(loginURL, qrterminal.L, os.Stdout)
+	pterm.Info.Printf("Or visit this link to view the QR code: %s\n", imageURL)
+	return nil
+}
+
+// SetQRHandler replaces the way QRLogin shows the QR code, nil restores TerminalQR
+func (s *Service) SetQRHandler(h QRHandler) {
+	s.qrHandler = h
+}
+
 // QRLogin performs QR code login using the Mi Home app.
 // Returns authentication data on success, error on failure.
 func (s *Service) QRLogin() (*Tokens, error) {
@@ -32,10 +49,12 @@ func (s *Service) QRLogin() (*Tokens, error) {
 
 	// Try to load existing token from store
 	if s.tokenStore != nil && s.token.PassToken == "" {
-		if tokens, err := s.tokenStore.LoadToken(); err == nil {
-			if tokens.UserName == s.username {
-				s.token = tokens
-			}
+		tokens, err := s.tokenStore.LoadToken()
+		if err == nil && tokens.UserName == s.username {
+			s.token = tokens
+		} else if err != nil && !isNoToken(err) {
+			// saving the new token would overwrite the one that failed to load
+			return nil, fmt.Errorf("load token: %w", err)
 		}
 	}
 
@@ -82,7 +101,7 @@ func (s *Service) QRLogin() (*Tokens, error) {
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get QR code URL: %w", err)
+		return nil, fmt.Errorf("%w: failed to get QR code URL: %v", ErrNetwork, err)
 	}
 	defer resp.Body.Close()
 
@@ -102,10 +121,14 @@ func (s *Service) QRLogin() (*Tokens, error) {
 		return nil, fmt.Errorf("QR code request failed: code=%d, desc=%s", qrResp.Code, qrResp.Desc)
 	}
 
-	// Step 3: Print QR code to terminal
-	pterm.Info.Println("Please scan the QR code below using the Mi Home app:")
-	qrterminal.GenerateHalfBlock(qrResp.LoginURL, qrterminal.L, os.Stdout)
-	pterm.Info.Printf("Or visit this link to view the QR code: %s\n", qrResp.QR)
+	// Step 3: Show the QR code, on the terminal unless SetQRHandler says otherwise
+	showQR := s.qrHandler
+	if showQR == nil {
+		showQR = TerminalQR
+	}
+	if err = showQR(qrResp.LoginURL, qrResp.QR); err != nil {
+		return nil, fmt.Errorf("failed to show QR code: %w", err)
+	}
 
 	// Step 4: Long polling for scan result
 	pterm.Info.Println("Waiting for QR code scan...")
@@ -122,7 +145,7 @@ func (s *Service) QRLogin() (*Tokens, error) {
 		if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "deadline") {
 			return nil, fmt.Errorf("QR code scan timed out, please try again")
 		}
-		return nil, fmt.Errorf("poll request failed: %w", err)
+		return nil, fmt.Errorf("%w: poll request failed: %v", ErrNetwork, err)
 	}
 	defer pollResp.Body.Close()
 
@@ -160,7 +183,7 @@ func (s *Service) QRLogin() (*Tokens, error) {
 
 	cbResp, err := s.client.Do(cbReq)
 	if err != nil {
-		return nil, fmt.Errorf("callback request failed: %w", err)
+		return nil, fmt.Errorf("%w: callback request failed: %v", ErrNetwork, err)
 	}
 	defer cbResp.Body.Close()
 
@@ -185,12 +208,15 @@ func (s *Service) QRLogin() (*Tokens, error) {
 	for sid := range s.token.Sids {
 		sidToken := s.token.Sids[sid]
 		sidToken.ServiceToken = serviceToken
+		sidToken.IssuedAt = time.Now().Unix()
 		s.token.Sids[sid] = sidToken
 	}
 
 	s.token.LoginMode = "qr"
 	s.token.SSecurity = pollData.Ssecurity
 
+	s.saveToken()
+
 	pterm.Success.Println("QR code login successful!")
 	return s.token, nil
 }
@@ -223,7 +249,7 @@ func (s *Service) getLocation() (map[string]string, error) {
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("service login request failed: %w", err)
+		return nil, fmt.Errorf("%w: service login request failed: %v", ErrNetwork, err)
 	}
 	defer resp.Body.Close()
 
@@ -262,6 +288,7 @@ func (s *Service) getLocation() (map[string]string, error) {
 						s.token.Sids[sid] = SidToken{
 							SSecurity:    serviceData.Ssecurity,
 							ServiceToken: cookie.Value,
+							IssuedAt:     time.Now().Unix(),
 						}
 					}
 				}
@@ -0,0 +1,58 @@
+package miservice
+
+import (
+	"fmt"
+
+	"github.com/pterm/pterm"
+)
+
+const sceneServiceUri = "/appgateway/miot/appsceneservice/AppSceneService"
+
+type SceneInfo struct {
+	ID     string `json:"scene_id"`
+	Name   string `json:"name"`
+	HomeID string `json:"home_id"`
+	Type   string `json:"scene_type,omitempty"`
+}
+
+// SceneList returns the manual and smart scenes of a home.
+func (s *IOService) SceneList(homeID string) (scenes []*SceneInfo, err error) {
+	var result interface{}
+	result, err = s.Request(sceneServiceUri+"/GetSceneList", map[string]interface{}{"home_id": homeID})
+	if err != nil {
+		return nil, err
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected map[string]interface{} for scene list result, got %T", result)
+	}
+	list, _ := resultMap["scene_info_list"].([]interface{})
+	scenes = make([]*SceneInfo, 0, len(list))
+	for _, item := range list {
+		scene, ok := item.(map[string]interface{})
+		if !ok {
+			pterm.Warning.Printf("scene item is not map[string]interface{}, got %T, skipping\n", item)
+			continue
+		}
+		info := &SceneInfo{
+			ID:     stringField(scene, "scene_id"),
+			Name:   stringField(scene, "name"),
+			HomeID: stringField(scene, "home_id"),
+			Type:   stringField(scene, "scene_type"),
+		}
+		if info.HomeID == "" {
+			info.HomeID = homeID
+		}
+		scenes = append(scenes, info)
+	}
+	return
+}
+
+// RunScene triggers a scene as if it was tapped in the Mi Home app.
+func (s *IOService) RunScene(sceneID string) error {
+	_, err := s.Request(sceneServiceUri+"/RunScene", map[string]interface{}{
+		"scene_id":    sceneID,
+		"trigger_key": "user.click",
+	})
+	return err
+}
@@ -0,0 +1,221 @@
+package miservice
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// redirectTransport sends every request, whatever its host, to the test server
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestIOService returns an IOService logged in with a fake token whose
+// cloud and account requests are answered by handler
+func newTestIOService(t *testing.T, handler http.HandlerFunc) *IOService {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	s := New("user", "pass", "cn", nil)
+	s.SetInteractive(false)
+	s.client.Transport = redirectTransport{target: target}
+	s.token = NewTokens()
+	s.token.UserName, s.token.UserId, s.token.DeviceId = "user", "1001", "DEVICE"
+	s.token.Sids[MiioSid] = SidToken{
+		SSecurity:    base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")),
+		ServiceToken: "service-token",
+	}
+	return NewIOService(s)
+}
+
+// sceneRequest is what the fake cloud saw of a scene call
+type sceneRequest struct {
+	method string
+	path   string
+	cookie string
+	form   url.Values
+}
+
+func recordScene(t *testing.T, got *sceneRequest, r *http.Request) {
+	t.Helper()
+	if err := r.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := r.Cookie("serviceToken")
+	if c != nil {
+		got.cookie = c.Value
+	}
+	got.method, got.path, got.form = r.Method, r.URL.Path, r.PostForm
+}
+
+func checkSigned(t *testing.T, got *sceneRequest, path, data string) {
+	t.Helper()
+	if got.method != http.MethodPost || got.path != path {
+		t.Fatalf("got %s %s, want POST %s", got.method, got.path, path)
+	}
+	if got.cookie != "service-token" {
+		t.Fatalf("got serviceToken cookie %q", got.cookie)
+	}
+	if got.form.Get("data") != data {
+		t.Fatalf("got data %s, want %s", got.form.Get("data"), data)
+	}
+	if got.form.Get("signature") == "" || got.form.Get("_nonce") == "" {
+		t.Fatalf("request is not signed: %v", got.form)
+	}
+}
+
+func TestSceneList(t *testing.T) {
+	var got sceneRequest
+	svc := newTestIOService(t, func(w http.ResponseWriter, r *http.Request) {
+		recordScene(t, &got, r)
+		_, _ = w.Write([]byte(`{"code":0,"message":"ok","result":{"scene_info_list":[
+			{"scene_id":"11","name":"Movie","scene_type":"manual"},
+			{"scene_id":12,"name":"Away","home_id":"77"},
+			"bogus"
+		]}}`))
+	})
+
+	scenes, err := svc.SceneList("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSigned(t, &got, "/app"+sceneServiceUri+"/GetSceneList", `{"home_id":"42"}`)
+	want := []SceneInfo{
+		{ID: "11", Name: "Movie", HomeID: "42", Type: "manual"},
+		{ID: "12", Name: "Away", HomeID: "77"},
+	}
+	if len(scenes) != len(want) {
+		t.Fatalf("got %d scenes, want %d", len(scenes), len(want))
+	}
+	for i, w := range want {
+		if *scenes[i] != w {
+			t.Fatalf("scene %d: got %+v, want %+v", i, *scenes[i], w)
+		}
+	}
+}
+
+func TestRunScene(t *testing.T) {
+	var got sceneRequest
+	svc := newTestIOService(t, func(w http.ResponseWriter, r *http.Request) {
+		recordScene(t, &got, r)
+		_, _ = w.Write([]byte(`{"code":0,"message":"ok","result":{}}`))
+	})
+
+	if err := svc.RunScene("11"); err != nil {
+		t.Fatal(err)
+	}
+	checkSigned(t, &got, "/app"+sceneServiceUri+"/RunScene", `{"scene_id":"11","trigger_key":"user.click"}`)
+}
+
+func TestSceneErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		// login answers the passport requests of the login retried after a 401
+		login string
+		is    error
+		code  int
+		want  string
+	}{
+		{
+			name:   "api error",
+			status: http.StatusOK,
+			body:   `{"code":-2,"message":"scene not found"}`,
+			code:   -2,
+			want:   "scene not found",
+		},
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			body:   `too many requests`,
+			is:     ErrRateLimited,
+		},
+		{
+			name:   "auth rejected and login fails",
+			status: http.StatusUnauthorized,
+			body:   `unauthorized`,
+			login:  `&&&START&&&{"code":70016,"qs":"q","sid":"xiaomiio","_sign":"s","callback":"c"}`,
+			is:     ErrAuthExpired,
+		},
+		{
+			name:   "auth message",
+			status: http.StatusOK,
+			body:   `{"code":3,"message":"auth err"}`,
+			login:  `&&&START&&&{"code":70016,"qs":"q","sid":"xiaomiio","_sign":"s","callback":"c"}`,
+			is:     ErrAuthExpired,
+		},
+		{
+			name:   "no result",
+			status: http.StatusOK,
+			body:   `{"code":0,"message":"ok"}`,
+			want:   "error " + sceneServiceUri + "/GetSceneList",
+		},
+		{
+			name:   "result of the wrong type",
+			status: http.StatusOK,
+			body:   `{"code":0,"message":"ok","result":[]}`,
+			want:   "expected map[string]interface{} for scene list result",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			svc := newTestIOService(t, func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/pass/") {
+					if tt.login == "" {
+						t.Errorf("unexpected login request %s", r.URL.Path)
+					}
+					_, _ = w.Write([]byte(tt.login))
+					return
+				}
+				calls++
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			_, err := svc.SceneList("42")
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Fatalf("got %v, want %v", err, tt.is)
+			}
+			if tt.want != "" && !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+			if calls != 1 {
+				t.Fatalf("scene list sent %d times, want 1", calls)
+			}
+			var apiErr *APIError
+			if tt.code != 0 && (!errors.As(err, &apiErr) || apiErr.Code != tt.code) {
+				t.Fatalf("got %v, want an APIError with code %d", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestSceneNetworkError(t *testing.T) {
+	svc := newTestIOService(t, func(w http.ResponseWriter, r *http.Request) {
+		hj, _ := w.(http.Hijacker)
+		conn, _, _ := hj.Hijack()
+		_ = conn.Close()
+	})
+	err := svc.RunScene("11")
+	if !errors.Is(err, ErrNetwork) {
+		t.Fatalf("got %v, want ErrNetwork", err)
+	}
+}
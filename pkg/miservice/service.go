@@ -11,6 +11,8 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"micli/pkg/util"
 
@@ -27,29 +29,37 @@ type Service struct {
 	tokenStore TokenStore
 	token      *Tokens
 	region     string
+	// interactive allows logins that wait for the user, i.e. the QR code
+	interactive    bool
+	qrHandler      QRHandler
+	captchaHandler CaptchaHandler
+	verifyHandler  VerifyHandler
+	// mu guards token, requests may be made from several goroutines
+	mu sync.RWMutex
 }
 
 type loginResp struct {
-	Qs             string      `json:"qs"`
-	Ssecurity      string      `json:"ssecurity"`
-	Code           int         `json:"code"`
-	PassToken      string      `json:"passToken"`
-	Description    string      `json:"description"`
-	SecurityStatus int         `json:"securityStatus"`
-	Nonce          int64       `json:"nonce"`
-	UserID         int         `json:"userId"`
-	CUserID        string      `json:"cUserId"`
-	Result         string      `json:"result"`
-	Psecurity      string      `json:"psecurity"`
-	CaptchaURL     interface{} `json:"captchaUrl"`
-	Location       string      `json:"location"`
-	Pwd            int         `json:"pwd"`
-	Child          int         `json:"child"`
-	Desc           string      `json:"desc"`
-	ServiceParam   string      `json:"serviceParam"`
-	Sign           string      `json:"_sign"`
-	Sid            string      `json:"sid"`
-	Callback       string      `json:"callback"`
+	Qs              string      `json:"qs"`
+	Ssecurity       string      `json:"ssecurity"`
+	Code            int         `json:"code"`
+	PassToken       string      `json:"passToken"`
+	Description     string      `json:"description"`
+	SecurityStatus  int         `json:"securityStatus"`
+	Nonce           int64       `json:"nonce"`
+	UserID          int         `json:"userId"`
+	CUserID         string      `json:"cUserId"`
+	Result          string      `json:"result"`
+	Psecurity       string      `json:"psecurity"`
+	CaptchaURL      interface{} `json:"captchaUrl"`
+	NotificationURL string      `json:"notificationUrl"`
+	Location        string      `json:"location"`
+	Pwd             int         `json:"pwd"`
+	Child           int         `json:"child"`
+	Desc            string      `json:"desc"`
+	ServiceParam    string      `json:"serviceParam"`
+	Sign            string      `json:"_sign"`
+	Sid             string      `json:"sid"`
+	Callback        string      `json:"callback"`
 }
 
 type DataCb func(tokens *Tokens, cookie map[string]string) url.Values
@@ -60,10 +70,11 @@ func New(username, password, region string, tokenStore TokenStore) *Service {
 		client: &http.Client{
 			Jar: j,
 		},
-		username:   username,
-		password:   password,
-		tokenStore: tokenStore,
-		region:     region,
+		username:    username,
+		password:    password,
+		tokenStore:  tokenStore,
+		region:      region,
+		interactive: true,
 	}
 }
 
@@ -72,25 +83,19 @@ func (s *Service) GetTokenStore() TokenStore {
 	return s.tokenStore
 }
 
-// Login 米家服务登录 - 优先尝试二维码登录，降级到密码登录
+// Login 米家服务登录 - 先用 passToken 续期，再尝试二维码登录，降级到密码登录
 func (s *Service) login(sid string) error {
-	var err error
-	defer func() {
-		if err != nil && s.tokenStore != nil {
-			_ = s.tokenStore.SaveToken(nil)
-		}
-	}()
-	if s.token == nil {
-		if s.tokenStore != nil {
-			var tokens *Tokens
-			tokens, err = s.tokenStore.LoadToken()
-			if err == nil {
-				if tokens.UserName != s.username {
-					_ = s.tokenStore.SaveToken(nil)
-				} else {
-					s.token = tokens
-				}
-			}
+	if s.token == nil && s.tokenStore != nil {
+		tokens, loadErr := s.tokenStore.LoadToken()
+		switch {
+		case loadErr == nil && tokens.UserName == s.username:
+			s.token = tokens
+		case loadErr == nil:
+			s.clearToken()
+		case !isNoToken(loadErr):
+			// a wrong passphrase or a locked keyring: logging in again would
+			// overwrite the stored token
+			return fmt.Errorf("load token: %w", loadErr)
 		}
 	}
 	if s.token == nil {
@@ -99,6 +104,30 @@ func (s *Service) login(sid string) error {
 		s.token.DeviceId = strings.ToUpper(util.GetRandom(16))
 	}
 
+	// A valid passToken renews the service token without asking anything
+	if s.token.PassToken != "" {
+		refreshErr := s.refreshSid(sid)
+		if refreshErr == nil {
+			return nil
+		}
+		pterm.Debug.Printf("passToken refresh failed: %v\n", refreshErr)
+		// only a passToken rejected by the server ends the stored session,
+		// other failures leave it for the next run
+		if errors.Is(refreshErr, ErrAuthExpired) {
+			s.clearToken()
+			s.token.PassToken = ""
+			s.token.Sids = make(map[string]SidToken)
+		}
+	}
+
+	if !s.interactive {
+		err := s.passwordLogin(sid)
+		if err != nil && !errors.Is(err, ErrNetwork) {
+			return fmt.Errorf("password login failed and QR login is disabled: %w", err)
+		}
+		return err
+	}
+
 	// Try QR login first (avoids captcha issues with password login)
 	pterm.Info.Println("Trying QR code login...")
 	_, qrErr := s.QRLogin()
@@ -142,13 +171,41 @@ func (s *Service) passwordLogin(sid string) error {
 			"user":     {s.username},
 			"hash":     {strings.ToUpper(fmt.Sprintf("%x", md5.Sum([]byte(s.password))))},
 		}
-		resp, err = s.serviceLogin("serviceLoginAuth2", data, cookies)
-		if err != nil {
-			// log.Println("serviceLoginAuth2 error", err)
-			return err
+		for tries := 0; ; tries++ {
+			resp, err = s.serviceLogin("serviceLoginAuth2", data, cookies)
+			if err != nil {
+				// log.Println("serviceLoginAuth2 error", err)
+				return err
+			}
+			captchaURL, _ := resp.CaptchaURL.(string)
+			if resp.Code != loginCodeCaptcha || captchaURL == "" {
+				break
+			}
+			if tries == maxCaptchaTries {
+				return fmt.Errorf("%w: too many wrong captchas", ErrCaptchaRequired)
+			}
+			var captCode string
+			if captCode, err = s.solveCaptcha(captchaURL); err != nil {
+				return err
+			}
+			data.Set("captCode", captCode)
 		}
 		if resp.Code != 0 {
-			return fmt.Errorf("code Error: %v", resp)
+			return loginError(resp)
+		}
+		// a login from a new device has to be confirmed by SMS or email, the
+		// passToken it sets then makes serviceLogin succeed
+		if resp.Location == "" && resp.NotificationURL != "" {
+			if err = s.verifyIdentity(resp.NotificationURL, sid); err != nil {
+				return err
+			}
+			resp, err = s.serviceLogin(fmt.Sprintf("serviceLogin?sid=%s&_json=true", sid), nil, cookies)
+			if err != nil {
+				return err
+			}
+			if resp.Code != 0 {
+				return loginError(resp)
+			}
 		}
 	}
 	s.token.UserId = fmt.Sprint(resp.UserID)
@@ -163,42 +220,53 @@ func (s *Service) passwordLogin(sid string) error {
 	s.token.Sids[sid] = SidToken{
 		SSecurity:    resp.Ssecurity,
 		ServiceToken: serviceToken,
+		IssuedAt:     time.Now().Unix(),
 	}
+	s.token.LoginMode = "password"
 
-	if s.tokenStore != nil {
-		_ = s.tokenStore.SaveToken(s.token)
-	}
+	s.saveToken()
 
 	return nil
 }
 
+// PasswordLogin logs in again with the username and password for sid,
+// replacing the saved session
+func (s *Service) PasswordLogin(sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = NewTokens()
+	s.token.UserName = s.username
+	s.token.DeviceId = strings.ToUpper(util.GetRandom(16))
+	return s.passwordLogin(sid)
+}
+
 // Request 请求
 func (s *Service) Request(sid, u string, data url.Values, cb DataCb, headers http.Header, reLogin bool, output any) error {
-	if !s.existSid(sid) {
-		err := s.login(sid)
-		if err != nil {
-			return err
-		}
+	if err := s.ensureLogin(sid); err != nil {
+		return err
 	}
 	// log.Println("request token done")
+	s.mu.RLock()
+	serviceToken := s.token.Sids[sid].ServiceToken
 	req := s.buildRequest(sid, u, data, cb, headers)
+	s.mu.RUnlock()
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrNetwork, err)
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
+	rs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNetwork, err)
+	}
+	apiErr := &APIError{URI: u, StatusCode: resp.StatusCode, Body: string(rs)}
 	if resp.StatusCode == http.StatusOK {
 		type _result struct {
 			Code    int    `json:"code"`
 			Message string `json:"message"`
 		}
-		var rs []byte
-		rs, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
 		// pterm.Println("response", u, string(rs))
 		var result *_result
 		err = json.Unmarshal(rs, &result)
@@ -209,20 +277,41 @@ func (s *Service) Request(sid, u string, data url.Values, cb DataCb, headers htt
 			err = json.Unmarshal(rs, output)
 			return err
 		}
+		apiErr.Code = result.Code
+		apiErr.Message = result.Message
 
 		if strings.Contains(strings.ToLower(result.Message), "auth") {
-			resp.StatusCode = http.StatusUnauthorized
+			apiErr.StatusCode = http.StatusUnauthorized
 		}
 	}
-	if resp.StatusCode == http.StatusUnauthorized && reLogin {
-		s.token = nil
-		if s.tokenStore != nil {
-			_ = s.tokenStore.SaveToken(nil)
+	if apiErr.StatusCode == http.StatusUnauthorized && reLogin {
+		s.mu.Lock()
+		// only the service token is dropped, the passToken can renew it;
+		// another request may have renewed it already
+		if s.token != nil && s.token.Sids[sid].ServiceToken == serviceToken {
+			delete(s.token.Sids, sid)
 		}
+		s.mu.Unlock()
 		return s.Request(sid, u, data, cb, headers, false, output)
 	}
-	body, _ := io.ReadAll(resp.Body)
-	return fmt.Errorf("error %s: %s", u, string(body))
+	return apiErr
+}
+
+// ensureLogin logs in when there is no token for sid yet
+func (s *Service) ensureLogin(sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.existSid(sid) {
+		return nil
+	}
+	err := s.login(sid)
+	if err != nil {
+		if errors.Is(err, ErrNetwork) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrAuthExpired, err)
+	}
+	return nil
 }
 
 // NewRequest 构造请求
@@ -298,7 +387,7 @@ func (s *Service) serviceLogin(uri string, data url.Values, cookies []*http.Cook
 	resp, err := s.client.Do(req)
 	if err != nil {
 		// log.Println("http do request error", err)
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrNetwork, err)
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
@@ -347,7 +436,7 @@ func (s *Service) securityTokenService(location, sSecurity string, nonce int64)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %v", ErrNetwork, err)
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
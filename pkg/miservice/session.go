@@ -0,0 +1,161 @@
+package miservice
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// DefaultTokenRefreshAge is the age after which StartRefresh renews a service token
+const DefaultTokenRefreshAge = 12 * time.Hour
+
+// SidStatus is the service token held for one sid
+type SidStatus struct {
+	Sid      string     `json:"sid"`
+	IssuedAt *time.Time `json:"issued_at,omitempty"`
+	// Age is in seconds, 0 when the token predates issue tracking
+	Age int64 `json:"age"`
+}
+
+// AuthStatus describes the session of a Service
+type AuthStatus struct {
+	User      string       `json:"user"`
+	UserId    string       `json:"user_id,omitempty"`
+	Region    string       `json:"region"`
+	LoggedIn  bool         `json:"logged_in"`
+	LoginMode string       `json:"login_mode,omitempty"`
+	PassToken bool         `json:"pass_token"`
+	Sids      []*SidStatus `json:"sids"`
+}
+
+// SetInteractive allows or forbids logins that wait for the user, i.e. the QR code.
+// A non-interactive Service renews tokens with the passToken and falls back to
+// the password only.
+func (s *Service) SetInteractive(interactive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interactive = interactive
+}
+
+// Status returns the session held in memory, or the stored one before the first request
+func (s *Service) Status() *AuthStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == nil && s.tokenStore != nil {
+		if tokens, err := s.tokenStore.LoadToken(); err == nil && tokens.UserName == s.username {
+			s.token = tokens
+		}
+	}
+	status := &AuthStatus{User: s.username, Region: s.region, Sids: []*SidStatus{}}
+	if s.token == nil {
+		return status
+	}
+	status.UserId = s.token.UserId
+	status.LoginMode = s.token.LoginMode
+	status.PassToken = s.token.PassToken != ""
+	status.LoggedIn = status.PassToken || len(s.token.Sids) > 0
+	for sid, t := range s.token.Sids {
+		st := &SidStatus{Sid: sid, Age: int64(t.Age().Seconds())}
+		if t.IssuedAt != 0 {
+			issued := time.Unix(t.IssuedAt, 0)
+			st.IssuedAt = &issued
+		}
+		status.Sids = append(status.Sids, st)
+	}
+	slices.SortFunc(status.Sids, func(a, b *SidStatus) int { return cmp.Compare(a.Sid, b.Sid) })
+	return status
+}
+
+// RefreshSid renews the service token of sid with the passToken, it never asks the user
+func (s *Service) RefreshSid(sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == nil && s.tokenStore != nil {
+		if tokens, err := s.tokenStore.LoadToken(); err == nil && tokens.UserName == s.username {
+			s.token = tokens
+		}
+	}
+	err := s.refreshSid(sid)
+	if err != nil && !errors.Is(err, ErrNetwork) && !errors.Is(err, ErrAuthExpired) {
+		return fmt.Errorf("%w: %v", ErrAuthExpired, err)
+	}
+	return err
+}
+
+// refreshSid is RefreshSid for callers holding mu
+func (s *Service) refreshSid(sid string) error {
+	if s.token == nil || s.token.PassToken == "" {
+		return fmt.Errorf("%w: no passToken, log in again", ErrAuthExpired)
+	}
+	cookies := []*http.Cookie{
+		{Name: "sdkVersion", Value: "3.9"},
+		{Name: "deviceId", Value: s.token.DeviceId},
+		{Name: "userId", Value: s.token.UserId},
+		{Name: "passToken", Value: s.token.PassToken},
+	}
+	resp, err := s.serviceLogin(fmt.Sprintf("serviceLogin?sid=%s&_json=true", sid), nil, cookies)
+	if err != nil {
+		return err
+	}
+	if resp.Code != 0 || resp.Location == "" {
+		return fmt.Errorf("%w: passToken rejected (code %d)", ErrAuthExpired, resp.Code)
+	}
+	serviceToken, err := s.securityTokenService(resp.Location, resp.Ssecurity, resp.Nonce)
+	if err != nil {
+		return err
+	}
+	if resp.PassToken != "" {
+		s.token.PassToken = resp.PassToken
+	}
+	s.token.Sids[sid] = SidToken{
+		SSecurity:    resp.Ssecurity,
+		ServiceToken: serviceToken,
+		IssuedAt:     time.Now().Unix(),
+	}
+	s.saveToken()
+	return nil
+}
+
+// StartRefresh checks the service tokens every interval until ctx is done and
+// renews the ones older than age, or of unknown age, with the passToken
+func (s *Service) StartRefresh(ctx context.Context, interval, age time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			for _, sid := range s.staleSids(age) {
+				if err := s.RefreshSid(sid); err != nil {
+					pterm.Warning.Printf("Fail to refresh the %s token: %v\n", sid, err)
+				} else {
+					pterm.Debug.Printf("Refreshed the %s token\n", sid)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Service) staleSids(age time.Duration) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.token == nil || s.token.PassToken == "" {
+		return nil
+	}
+	var sids []string
+	for sid, t := range s.token.Sids {
+		if t.IssuedAt == 0 || t.Age() >= age {
+			sids = append(sids, sid)
+		}
+	}
+	return sids
+}
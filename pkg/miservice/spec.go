@@ -0,0 +1,531 @@
+package miservice
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Service returns the service with the given siid, or nil.
+func (d *MiotSpecInstancesData) Service(siid int) *MiotSpecService {
+	for _, s := range d.Services {
+		if s.Iid == siid {
+			return s
+		}
+	}
+	return nil
+}
+
+// Property returns the property siid-piid, or nil.
+func (d *MiotSpecInstancesData) Property(siid, piid int) *MiotSpecProperty {
+	if s := d.Service(siid); s != nil {
+		return s.Property(piid)
+	}
+	return nil
+}
+
+// Property returns the property with the given piid, or nil.
+func (s *MiotSpecService) Property(piid int) *MiotSpecProperty {
+	for _, p := range s.Properties {
+		if p.Iid == piid {
+			return p
+		}
+	}
+	return nil
+}
+
+// Action returns the action with the given aiid, or nil.
+func (s *MiotSpecService) Action(aiid int) *MiotSpecAction {
+	for _, a := range s.Actions {
+		if a.Iid == aiid {
+			return a
+		}
+	}
+	return nil
+}
+
+func (p *MiotSpecProperty) can(access string) bool {
+	for _, a := range p.Access {
+		if a == access {
+			return true
+		}
+	}
+	return false
+}
+
+// Readable reports whether the property can be read.
+func (p *MiotSpecProperty) Readable() bool {
+	return p.can("read")
+}
+
+// Writable reports whether the property can be written.
+func (p *MiotSpecProperty) Writable() bool {
+	return p.can("write")
+}
+
+// CheckWrite validates a value written to the property, see Coerce.
+func (p *MiotSpecProperty) CheckWrite(v interface{}, clamp bool) (interface{}, error) {
+	if !p.Writable() {
+		return nil, fmt.Errorf("property %q (piid:%d) is read-only", p.Description, p.Iid)
+	}
+	return p.Coerce(v, clamp)
+}
+
+// Coerce converts v to the declared format of the property. Value-list
+// descriptions are mapped to their codes, values outside the value-range or off
+// its step are refused, or moved to the nearest valid value when clamp is set.
+func (p *MiotSpecProperty) Coerce(v interface{}, clamp bool) (interface{}, error) {
+	s := strings.TrimSpace(fmt.Sprint(v))
+	if len(p.ValueList) > 0 {
+		return p.coerceList(s)
+	}
+	switch p.Format {
+	case "bool":
+		switch strings.ToLower(s) {
+		case "true", "1", "on", "yes":
+			return true, nil
+		case "false", "0", "off", "no":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a bool", s)
+	case "string":
+		return s, nil
+	case "float":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return p.checkRange(f, clamp)
+	case "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f != math.Trunc(f) {
+			return nil, fmt.Errorf("%q is not an integer", s)
+		}
+		lo, hi := formatBounds(p.Format)
+		if f < lo || f > hi {
+			if !clamp {
+				return nil, fmt.Errorf("%v is out of %s range", f, p.Format)
+			}
+			f = math.Max(lo, math.Min(hi, f))
+		}
+		res, err := p.checkRange(f, clamp)
+		if err != nil {
+			return nil, err
+		}
+		return int64(res.(float64)), nil
+	}
+	return v, nil
+}
+
+func (p *MiotSpecProperty) coerceList(s string) (interface{}, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		for _, item := range p.ValueList {
+			if item.Value == n {
+				return n, nil
+			}
+		}
+	}
+	for _, item := range p.ValueList {
+		if strings.EqualFold(item.Description, s) || strings.EqualFold(strings.ReplaceAll(item.Description, " ", "-"), s) {
+			return item.Value, nil
+		}
+	}
+	choices := make([]string, len(p.ValueList))
+	for i, item := range p.ValueList {
+		choices[i] = fmt.Sprintf("%d(%s)", item.Value, item.Description)
+	}
+	return nil, fmt.Errorf("%q is not one of %s", s, strings.Join(choices, ", "))
+}
+
+func (p *MiotSpecProperty) checkRange(f float64, clamp bool) (interface{}, error) {
+	if len(p.ValueRange) < 2 {
+		return f, nil
+	}
+	min, okMin := toFloat(p.ValueRange[0])
+	max, okMax := toFloat(p.ValueRange[1])
+	if !okMin || !okMax {
+		return f, nil
+	}
+	if f < min || f > max {
+		if !clamp {
+			return nil, fmt.Errorf("%v is out of range [%v, %v]", f, min, max)
+		}
+		f = math.Max(min, math.Min(max, f))
+	}
+	if len(p.ValueRange) > 2 {
+		if step, ok := toFloat(p.ValueRange[2]); ok && step > 0 {
+			n := (f - min) / step
+			// allow float noise, e.g. 0.1 steps
+			if math.Abs(n-math.Round(n)) > 1e-9 {
+				if !clamp {
+					return nil, fmt.Errorf("%v is not on step %v from %v", f, step, min)
+				}
+				f = min + math.Round(n)*step
+				if f > max {
+					f = min + math.Floor((max-min)/step)*step
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+func formatBounds(format string) (float64, float64) {
+	switch format {
+	case "int8":
+		return math.MinInt8, math.MaxInt8
+	case "int16":
+		return math.MinInt16, math.MaxInt16
+	case "int32":
+		return math.MinInt32, math.MaxInt32
+	case "uint8":
+		return 0, math.MaxUint8
+	case "uint16":
+		return 0, math.MaxUint16
+	case "uint32":
+		return 0, math.MaxUint32
+	case "uint64":
+		return 0, math.MaxUint64
+	}
+	return math.MinInt64, math.MaxInt64
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ActionArgs checks the in arguments of action aiid against the properties
+// they refer to and converts each of them, see Coerce.
+func (s *MiotSpecService) ActionArgs(aiid int, args []interface{}, clamp bool) ([]interface{}, error) {
+	a := s.Action(aiid)
+	if a == nil {
+		return nil, fmt.Errorf("action %d-%d does not exist", s.Iid, aiid)
+	}
+	if len(args) != len(a.In) {
+		return nil, fmt.Errorf("action %q (aiid:%d) takes %d arguments, got %d", a.Description, a.Iid, len(a.In), len(args))
+	}
+	res := make([]interface{}, len(args))
+	for i, in := range a.In {
+		p := s.Property(in)
+		if p == nil {
+			res[i] = args[i]
+			continue
+		}
+		v, err := p.Coerce(args[i], clamp)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %w", i+1, p.Description, err)
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// ActionOutput is one out value of an action, decoded against the spec.
+type ActionOutput struct {
+	Piid        int         `json:"piid"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Value       interface{} `json:"value"`
+	Text        string      `json:"text,omitempty"`
+}
+
+// ActionOutputs decodes the out values of action aiid against the properties
+// they refer to. Values the spec does not describe, or all of them when s is
+// nil, are kept as they are.
+func (s *MiotSpecService) ActionOutputs(aiid int, out []interface{}) []*ActionOutput {
+	var piids []int
+	if s != nil {
+		if a := s.Action(aiid); a != nil {
+			piids = a.Out
+		}
+	}
+	res := make([]*ActionOutput, len(out))
+	for i, v := range out {
+		o := &ActionOutput{Value: v}
+		// some devices answer {"piid":..,"value":..} items instead of bare values
+		if m, ok := v.(map[string]interface{}); ok && m["piid"] != nil {
+			o.Piid, o.Value = toInt(m["piid"]), m["value"]
+		} else if i < len(piids) {
+			o.Piid = piids[i]
+		}
+		if s != nil {
+			if p := s.Property(o.Piid); p != nil {
+				o.Name = ShortName(p.Type)
+				o.Description = p.Description
+				o.Value = p.Decode(o.Value)
+				o.Text = p.ValueText(o.Value)
+			}
+		}
+		res[i] = o
+	}
+	return res
+}
+
+// Decode converts a value read from the device to the declared format,
+// numbers become bools or integers where the spec says so.
+func (p *MiotSpecProperty) Decode(v interface{}) interface{} {
+	f, ok := v.(float64)
+	if !ok {
+		return v
+	}
+	switch p.Format {
+	case "bool":
+		return f != 0
+	case "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64":
+		if f == math.Trunc(f) {
+			return int64(f)
+		}
+	}
+	return v
+}
+
+// ValueText returns the value-list description of v, or "".
+func (p *MiotSpecProperty) ValueText(v interface{}) string {
+	f, ok := toFloat(v)
+	if !ok {
+		return ""
+	}
+	for _, item := range p.ValueList {
+		if float64(item.Value) == f {
+			return item.Description
+		}
+	}
+	return ""
+}
+
+// ShortName returns the name part of a spec type URN, e.g. "brightness" for
+// urn:miot-spec-v2:property:brightness:0000000D:yeelink-ceiling4:1
+func ShortName(urn string) string {
+	parts := strings.Split(urn, ":")
+	if len(parts) > 3 {
+		return parts[3]
+	}
+	return ""
+}
+
+// ResolveProperty finds a property by "siid-piid", "service.property" or a
+// bare property name. Names are matched against the URN short names and the
+// descriptions, exact matches first, then prefixes, substrings and small typos.
+func (d *MiotSpecInstancesData) ResolveProperty(name string) (*MiotSpecService, *MiotSpecProperty, error) {
+	if siid, iid, ok := parseIids(name); ok {
+		s := d.Service(siid)
+		if s == nil {
+			return nil, nil, fmt.Errorf("service %d does not exist", siid)
+		}
+		p := s.Property(iid)
+		if p == nil {
+			return nil, nil, fmt.Errorf("property %d-%d does not exist", siid, iid)
+		}
+		return s, p, nil
+	}
+	var found []*MiotSpecService
+	var props []*MiotSpecProperty
+	d.resolve(name, func(s *MiotSpecService) []specName {
+		names := make([]specName, len(s.Properties))
+		for i, p := range s.Properties {
+			names[i] = specName{short: ShortName(p.Type), description: p.Description}
+		}
+		return names
+	}, func(s *MiotSpecService, i int) {
+		found = append(found, s)
+		props = append(props, s.Properties[i])
+	})
+	switch len(props) {
+	case 0:
+		return nil, nil, fmt.Errorf("property %q not found", name)
+	case 1:
+		return found[0], props[0], nil
+	}
+	candidates := make([]string, len(props))
+	for i, p := range props {
+		candidates[i] = fmt.Sprintf("%d.%s (%d-%d %s)", found[i].Iid, specLabel(p.Type, p.Description), found[i].Iid, p.Iid, found[i].Description)
+	}
+	return nil, nil, fmt.Errorf("property %q is ambiguous: %s", name, strings.Join(candidates, ", "))
+}
+
+// ResolveAction finds an action by "siid-aiid", "service.action" or a bare action name, see ResolveProperty.
+func (d *MiotSpecInstancesData) ResolveAction(name string) (*MiotSpecService, *MiotSpecAction, error) {
+	if siid, iid, ok := parseIids(name); ok {
+		s := d.Service(siid)
+		if s == nil {
+			return nil, nil, fmt.Errorf("service %d does not exist", siid)
+		}
+		a := s.Action(iid)
+		if a == nil {
+			return nil, nil, fmt.Errorf("action %d-%d does not exist", siid, iid)
+		}
+		return s, a, nil
+	}
+	var found []*MiotSpecService
+	var actions []*MiotSpecAction
+	d.resolve(name, func(s *MiotSpecService) []specName {
+		names := make([]specName, len(s.Actions))
+		for i, a := range s.Actions {
+			names[i] = specName{short: ShortName(a.Type), description: a.Description}
+		}
+		return names
+	}, func(s *MiotSpecService, i int) {
+		found = append(found, s)
+		actions = append(actions, s.Actions[i])
+	})
+	switch len(actions) {
+	case 0:
+		return nil, nil, fmt.Errorf("action %q not found", name)
+	case 1:
+		return found[0], actions[0], nil
+	}
+	candidates := make([]string, len(actions))
+	for i, a := range actions {
+		candidates[i] = fmt.Sprintf("%d.%s (%d-%d %s)", found[i].Iid, specLabel(a.Type, a.Description), found[i].Iid, a.Iid, found[i].Description)
+	}
+	return nil, nil, fmt.Errorf("action %q is ambiguous: %s", name, strings.Join(candidates, ", "))
+}
+
+type specName struct {
+	short       string
+	description string
+}
+
+// resolve matches "service.member" or "member" and calls found for every
+// matching member. The service part may also be a siid. Names such as
+// pm2.5-density contain dots themselves, so a dotted name is first matched
+// whole against the members of every service, typos aside.
+func (d *MiotSpecInstancesData) resolve(name string, members func(*MiotSpecService) []specName, found func(*MiotSpecService, int)) {
+	srv, member, ok := strings.Cut(name, ".")
+	if !ok {
+		d.resolveMember(d.Services, name, true, members, found)
+		return
+	}
+	if d.resolveMember(d.Services, name, false, members, found) {
+		return
+	}
+	var services []*MiotSpecService
+	if siid, err := strconv.Atoi(srv); err == nil {
+		if s := d.Service(siid); s != nil {
+			services = []*MiotSpecService{s}
+		}
+	} else {
+		names := make([]specName, len(d.Services))
+		for i, s := range d.Services {
+			names[i] = specName{short: ShortName(s.Type), description: s.Description}
+		}
+		for _, i := range matchNames(srv, names, true) {
+			services = append(services, d.Services[i])
+		}
+	}
+	d.resolveMember(services, member, true, members, found)
+}
+
+// resolveMember matches member over all candidate services at once, so an
+// exact match in one service wins over a fuzzy one in another. It reports
+// whether anything matched.
+func (d *MiotSpecInstancesData) resolveMember(services []*MiotSpecService, member string, fuzzy bool, members func(*MiotSpecService) []specName, found func(*MiotSpecService, int)) bool {
+	type ref struct {
+		s *MiotSpecService
+		i int
+	}
+	var refs []ref
+	var names []specName
+	for _, s := range services {
+		for i, n := range members(s) {
+			refs = append(refs, ref{s, i})
+			names = append(names, n)
+		}
+	}
+	matched := matchNames(member, names, fuzzy)
+	for _, i := range matched {
+		found(refs[i].s, refs[i].i)
+	}
+	return len(matched) > 0
+}
+
+// matchNames returns the indexes of the best matching names: exact matches if
+// any, else prefix matches, else substring matches, else with fuzzy names
+// within two edits.
+func matchNames(query string, names []specName, fuzzy bool) []int {
+	q := normalizeName(query)
+	if q == "" {
+		return nil
+	}
+	tiers := []func(string) bool{
+		func(n string) bool { return n == q },
+		func(n string) bool { return strings.HasPrefix(n, q) },
+		func(n string) bool { return strings.Contains(n, q) },
+	}
+	if fuzzy {
+		tiers = append(tiers, func(n string) bool { return len(q) > 3 && levenshtein(n, q) <= 2 })
+	}
+	for _, match := range tiers {
+		var res []int
+		for i, n := range names {
+			if match(normalizeName(n.short)) || match(normalizeName(n.description)) {
+				res = append(res, i)
+			}
+		}
+		if len(res) > 0 {
+			return res
+		}
+	}
+	return nil
+}
+
+func normalizeName(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(s)
+}
+
+func specLabel(urn, description string) string {
+	if short := ShortName(urn); short != "" {
+		return short
+	}
+	return normalizeName(description)
+}
+
+func parseIids(s string) (int, int, bool) {
+	a, b, found := strings.Cut(s, "-")
+	siid, err := strconv.Atoi(a)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !found {
+		return siid, 1, true
+	}
+	iid, err := strconv.Atoi(b)
+	if err != nil {
+		return 0, 0, false
+	}
+	return siid, iid, true
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,213 @@
+package miservice
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"micli/pkg/util"
+)
+
+// DefaultSpecTTL is how long cached spec documents are used before they are fetched again.
+const DefaultSpecTTL = 7 * 24 * time.Hour
+
+// SpecIndex is the cached model to type list of miot-spec.org.
+type SpecIndex struct {
+	FetchedAt int64               `json:"fetched_at"`
+	Instances []*MiotSpecInstance `json:"instances"`
+}
+
+// SpecEntry is one cached instance document.
+type SpecEntry struct {
+	Type      string                 `json:"type"`
+	Model     string                 `json:"model,omitempty"`
+	Version   int                    `json:"version"`
+	FetchedAt int64                  `json:"fetched_at"`
+	Data      *MiotSpecInstancesData `json:"data"`
+}
+
+// Types maps each model to its type URN, preferring released instances and
+// then the highest version.
+func (x *SpecIndex) Types() map[string]string {
+	best := make(map[string]*MiotSpecInstance, len(x.Instances))
+	for _, in := range x.Instances {
+		if cur, ok := best[in.Model]; !ok || newerInstance(in, cur) {
+			best[in.Model] = in
+		}
+	}
+	types := make(map[string]string, len(best))
+	for model, in := range best {
+		types[model] = in.Type
+	}
+	return types
+}
+
+func newerInstance(a, b *MiotSpecInstance) bool {
+	aReleased, bReleased := a.Status == "released", b.Status == "released"
+	if aReleased != bReleased {
+		return aReleased
+	}
+	return a.Version > b.Version
+}
+
+// Age is the time since the entry was downloaded.
+func (e *SpecEntry) Age() time.Duration {
+	return time.Since(time.Unix(e.FetchedAt, 0))
+}
+
+// SpecCache keeps miot-spec.org documents on disk so spec lookups work
+// without a round trip, and offline.
+type SpecCache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// DefaultSpecCacheDir returns <user cache dir>/micli/miot-spec, or ./data/miot-spec
+// when the platform has no cache dir.
+func DefaultSpecCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join("data", "miot-spec")
+	}
+	return filepath.Join(dir, "micli", "miot-spec")
+}
+
+func NewSpecCache(dir string, ttl time.Duration) *SpecCache {
+	if dir == "" {
+		dir = DefaultSpecCacheDir()
+	}
+	if ttl <= 0 {
+		ttl = DefaultSpecTTL
+	}
+	return &SpecCache{Dir: dir, TTL: ttl}
+}
+
+func (c *SpecCache) indexPath() string {
+	return filepath.Join(c.Dir, "instances.json")
+}
+
+func (c *SpecCache) entryPath(typ string) string {
+	return filepath.Join(c.Dir, "instances", url.PathEscape(typ)+".json")
+}
+
+// Fresh reports whether a document fetched at ts is still within the TTL.
+func (c *SpecCache) Fresh(ts int64) bool {
+	return time.Since(time.Unix(ts, 0)) < c.TTL
+}
+
+// Index returns the cached instance list.
+func (c *SpecCache) Index() (*SpecIndex, error) {
+	var index *SpecIndex
+	if err := readJSON(c.indexPath(), &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func (c *SpecCache) SaveIndex(index *SpecIndex) error {
+	return writeJSON(c.indexPath(), index)
+}
+
+// Get returns the cached document of a type URN.
+func (c *SpecCache) Get(typ string) (*SpecEntry, error) {
+	var entry *SpecEntry
+	if err := readJSON(c.entryPath(typ), &entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (c *SpecCache) Put(entry *SpecEntry) error {
+	return writeJSON(c.entryPath(entry.Type), entry)
+}
+
+// List returns every cached document, sorted by type.
+func (c *SpecCache) List() ([]*SpecEntry, error) {
+	files, err := filepath.Glob(filepath.Join(c.Dir, "instances", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*SpecEntry, 0, len(files))
+	for _, f := range files {
+		var entry *SpecEntry
+		if err = readJSON(f, &entry); err != nil || entry == nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Type < entries[j].Type })
+	return entries, nil
+}
+
+// Prune removes every version of a model but the latest, and with a positive
+// maxAge the documents older than that. Stale documents are kept otherwise,
+// MiotSpec falls back to them when miot-spec.org can not be reached. It
+// returns the removed types.
+func (c *SpecCache) Prune(maxAge time.Duration) ([]string, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]int)
+	for _, e := range entries {
+		key := specTypeKey(e.Type)
+		if e.Version > latest[key] {
+			latest[key] = e.Version
+		}
+	}
+	var removed []string
+	for _, e := range entries {
+		if (maxAge <= 0 || e.Age() < maxAge) && e.Version >= latest[specTypeKey(e.Type)] {
+			continue
+		}
+		if err = os.Remove(c.entryPath(e.Type)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, err
+		}
+		removed = append(removed, e.Type)
+	}
+	return removed, nil
+}
+
+// specVersion returns the trailing version of a type URN,
+// urn:miot-spec-v2:device:light:0000A001:yeelink-ceiling4:2 is version 2.
+func specVersion(typ string) int {
+	parts := strings.Split(typ, ":")
+	v, _ := strconv.Atoi(parts[len(parts)-1])
+	return v
+}
+
+// specTypeKey is the type URN without its version.
+func specTypeKey(typ string) string {
+	if i := strings.LastIndex(typ, ":"); i > 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+func readJSON(p string, v interface{}) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	return json.NewDecoder(f).Decode(v)
+}
+
+func writeJSON(p string, v interface{}) error {
+	f, err := util.CreatNestedFile(p)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	return json.NewEncoder(f).Encode(v)
+}
@@ -0,0 +1,268 @@
+package miservice
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of spec differences.
+const (
+	SpecAdded   = "added"
+	SpecRemoved = "removed"
+	SpecMoved   = "moved"
+	SpecRenamed = "renamed"
+	SpecChanged = "changed"
+)
+
+// SpecDiff lists the differences between two spec documents.
+type SpecDiff struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Services []*ServiceDiff `json:"services,omitempty"`
+}
+
+// ServiceDiff is a service that differs between two specs. Iids are 0 on the
+// side the service is missing from.
+type ServiceDiff struct {
+	Change     string        `json:"change"`
+	Name       string        `json:"name"`
+	OldName    string        `json:"old_name,omitempty"`
+	OldSiid    int           `json:"old_siid,omitempty"`
+	NewSiid    int           `json:"new_siid,omitempty"`
+	Properties []*MemberDiff `json:"properties,omitempty"`
+	Actions    []*MemberDiff `json:"actions,omitempty"`
+}
+
+// MemberDiff is a property or action that differs between two specs.
+type MemberDiff struct {
+	Change  string         `json:"change"`
+	Name    string         `json:"name"`
+	OldName string         `json:"old_name,omitempty"`
+	OldIid  int            `json:"old_iid,omitempty"`
+	NewIid  int            `json:"new_iid,omitempty"`
+	Details []*FieldChange `json:"details,omitempty"`
+}
+
+// FieldChange is a changed attribute of a property or action.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Empty reports whether the specs are equivalent.
+func (d *SpecDiff) Empty() bool {
+	return len(d.Services) == 0
+}
+
+// DiffSpecs compares two spec documents. Services, properties and actions are
+// paired by iid and short name first, then by name alone (moved), then by iid
+// alone (renamed); whatever is left was added or removed.
+func DiffSpecs(a, b *MiotSpecInstancesData) *SpecDiff {
+	diff := &SpecDiff{From: a.Type, To: b.Type}
+	keys := func(services []*MiotSpecService) []specKey {
+		res := make([]specKey, len(services))
+		for i, s := range services {
+			res[i] = specKey{s.Iid, specKeyName(s.Type, s.Description)}
+		}
+		return res
+	}
+	pairs, removed, added := pairSpecKeys(keys(a.Services), keys(b.Services))
+	for _, p := range pairs {
+		sa, sb := a.Services[p[0]], b.Services[p[1]]
+		sd := &ServiceDiff{Name: specKeyName(sb.Type, sb.Description), OldSiid: sa.Iid, NewSiid: sb.Iid}
+		sd.Change = memberChange(sa.Iid, sb.Iid, specKeyName(sa.Type, sa.Description), sd.Name)
+		if sd.Change == SpecRenamed {
+			sd.OldName = specKeyName(sa.Type, sa.Description)
+		}
+		sd.Properties = diffProperties(sa, sb)
+		sd.Actions = diffActions(sa, sb)
+		if sd.Change == "" {
+			if len(sd.Properties) == 0 && len(sd.Actions) == 0 {
+				continue
+			}
+			sd.Change = SpecChanged
+		}
+		diff.Services = append(diff.Services, sd)
+	}
+	for _, i := range removed {
+		s := a.Services[i]
+		diff.Services = append(diff.Services, &ServiceDiff{Change: SpecRemoved, Name: specKeyName(s.Type, s.Description), OldSiid: s.Iid})
+	}
+	for _, i := range added {
+		s := b.Services[i]
+		diff.Services = append(diff.Services, &ServiceDiff{Change: SpecAdded, Name: specKeyName(s.Type, s.Description), NewSiid: s.Iid})
+	}
+	return diff
+}
+
+func diffProperties(a, b *MiotSpecService) []*MemberDiff {
+	keys := func(props []*MiotSpecProperty) []specKey {
+		res := make([]specKey, len(props))
+		for i, p := range props {
+			res[i] = specKey{p.Iid, specKeyName(p.Type, p.Description)}
+		}
+		return res
+	}
+	pairs, removed, added := pairSpecKeys(keys(a.Properties), keys(b.Properties))
+	var res []*MemberDiff
+	for _, p := range pairs {
+		pa, pb := a.Properties[p[0]], b.Properties[p[1]]
+		var details []*FieldChange
+		details = appendDetail(details, "description", pa.Description, pb.Description)
+		details = appendDetail(details, "format", pa.Format, pb.Format)
+		details = appendDetail(details, "access", strings.Join(pa.Access, ","), strings.Join(pb.Access, ","))
+		details = appendDetail(details, "value-range", formatRange(pa.ValueRange), formatRange(pb.ValueRange))
+		details = appendDetail(details, "value-list", formatValueList(pa), formatValueList(pb))
+		if md := newMemberDiff(pa.Iid, pb.Iid, specKeyName(pa.Type, pa.Description), specKeyName(pb.Type, pb.Description), details); md != nil {
+			res = append(res, md)
+		}
+	}
+	for _, i := range removed {
+		p := a.Properties[i]
+		res = append(res, &MemberDiff{Change: SpecRemoved, Name: specKeyName(p.Type, p.Description), OldIid: p.Iid})
+	}
+	for _, i := range added {
+		p := b.Properties[i]
+		res = append(res, &MemberDiff{Change: SpecAdded, Name: specKeyName(p.Type, p.Description), NewIid: p.Iid})
+	}
+	return res
+}
+
+func diffActions(a, b *MiotSpecService) []*MemberDiff {
+	keys := func(actions []*MiotSpecAction) []specKey {
+		res := make([]specKey, len(actions))
+		for i, x := range actions {
+			res[i] = specKey{x.Iid, specKeyName(x.Type, x.Description)}
+		}
+		return res
+	}
+	pairs, removed, added := pairSpecKeys(keys(a.Actions), keys(b.Actions))
+	var res []*MemberDiff
+	for _, p := range pairs {
+		aa, ab := a.Actions[p[0]], b.Actions[p[1]]
+		var details []*FieldChange
+		details = appendDetail(details, "description", aa.Description, ab.Description)
+		details = appendDetail(details, "in", fmt.Sprint(aa.In), fmt.Sprint(ab.In))
+		details = appendDetail(details, "out", fmt.Sprint(aa.Out), fmt.Sprint(ab.Out))
+		if md := newMemberDiff(aa.Iid, ab.Iid, specKeyName(aa.Type, aa.Description), specKeyName(ab.Type, ab.Description), details); md != nil {
+			res = append(res, md)
+		}
+	}
+	for _, i := range removed {
+		x := a.Actions[i]
+		res = append(res, &MemberDiff{Change: SpecRemoved, Name: specKeyName(x.Type, x.Description), OldIid: x.Iid})
+	}
+	for _, i := range added {
+		x := b.Actions[i]
+		res = append(res, &MemberDiff{Change: SpecAdded, Name: specKeyName(x.Type, x.Description), NewIid: x.Iid})
+	}
+	return res
+}
+
+// newMemberDiff returns the difference of a paired member, or nil when there is none.
+func newMemberDiff(oldIid, newIid int, oldName, newName string, details []*FieldChange) *MemberDiff {
+	md := &MemberDiff{Name: newName, OldIid: oldIid, NewIid: newIid, Details: details}
+	md.Change = memberChange(oldIid, newIid, oldName, newName)
+	switch md.Change {
+	case SpecRenamed:
+		md.OldName = oldName
+	case "":
+		if len(details) == 0 {
+			return nil
+		}
+		md.Change = SpecChanged
+	}
+	return md
+}
+
+func memberChange(oldIid, newIid int, oldName, newName string) string {
+	switch {
+	case oldIid != newIid:
+		return SpecMoved
+	case oldName != newName:
+		return SpecRenamed
+	}
+	return ""
+}
+
+func appendDetail(details []*FieldChange, field, a, b string) []*FieldChange {
+	if a == b {
+		return details
+	}
+	return append(details, &FieldChange{Field: field, Old: a, New: b})
+}
+
+func formatRange(r []interface{}) string {
+	if len(r) == 0 {
+		return ""
+	}
+	parts := make([]string, len(r))
+	for i, v := range r {
+		parts[i] = fmt.Sprint(v)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+func formatValueList(p *MiotSpecProperty) string {
+	parts := make([]string, len(p.ValueList))
+	for i, v := range p.ValueList {
+		parts[i] = fmt.Sprintf("%d(%s)", v.Value, v.Description)
+	}
+	return strings.Join(parts, ",")
+}
+
+type specKey struct {
+	iid  int
+	name string
+}
+
+// specKeyName is the short name of a URN, or the normalized description when
+// the URN has none.
+func specKeyName(urn, description string) string {
+	if name := ShortName(urn); name != "" {
+		return name
+	}
+	return normalizeName(description)
+}
+
+// pairSpecKeys pairs the indexes of a and b, see DiffSpecs. It returns the
+// pairs in the order of b and the unpaired indexes of both sides.
+func pairSpecKeys(a, b []specKey) (pairs [][2]int, removed, added []int) {
+	pa := make([]int, len(b))
+	for i := range pa {
+		pa[i] = -1
+	}
+	used := make([]bool, len(a))
+	rules := []func(x, y specKey) bool{
+		func(x, y specKey) bool { return x.iid == y.iid && x.name == y.name },
+		func(x, y specKey) bool { return x.name == y.name },
+		func(x, y specKey) bool { return x.iid == y.iid },
+	}
+	for _, match := range rules {
+		for j := range b {
+			if pa[j] >= 0 {
+				continue
+			}
+			for i := range a {
+				if !used[i] && match(a[i], b[j]) {
+					pa[j], used[i] = i, true
+					break
+				}
+			}
+		}
+	}
+	for j, i := range pa {
+		if i >= 0 {
+			pairs = append(pairs, [2]int{i, j})
+		} else {
+			added = append(added, j)
+		}
+	}
+	for i, u := range used {
+		if !u {
+			removed = append(removed, i)
+		}
+	}
+	return pairs, removed, added
+}
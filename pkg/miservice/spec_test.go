@@ -0,0 +1,70 @@
+package miservice
+
+import (
+	"strings"
+	"testing"
+)
+
+// purifierSpec is a trimmed zhimi air purifier spec
+const purifierSpec = `{
+	"type": "urn:miot-spec-v2:device:air-purifier:0000A007:zhimi-ma4:1",
+	"description": "Air Purifier",
+	"services": [
+		{"iid": 2, "type": "urn:miot-spec-v2:service:air-purifier:00007811:zhimi-ma4:1", "description": "Air Purifier",
+			"properties": [
+				{"iid": 1, "type": "urn:miot-spec-v2:property:on:00000006:zhimi-ma4:1", "description": "Switch Status", "format": "bool"},
+				{"iid": 4, "type": "urn:miot-spec-v2:property:mode:00000008:zhimi-ma4:1", "description": "Mode", "format": "uint8"}
+			]},
+		{"iid": 3, "type": "urn:miot-spec-v2:service:environment:0000780A:zhimi-ma4:1", "description": "Environment",
+			"properties": [
+				{"iid": 4, "type": "urn:miot-spec-v2:property:pm2.5-density:00000034:zhimi-ma4:1", "description": "PM2.5 Density", "format": "float"},
+				{"iid": 7, "type": "urn:miot-spec-v2:property:relative-humidity:0000000C:zhimi-ma4:1", "description": "Relative Humidity", "format": "uint8"}
+			]},
+		{"iid": 9, "type": "urn:miot-spec-v2:service:alarm:00007804:zhimi-ma4:1", "description": "Alarm",
+			"properties": [
+				{"iid": 1, "type": "urn:miot-spec-v2:property:on:00000006:zhimi-ma4:1", "description": "Alarm", "format": "bool"}
+			]}
+	]
+}`
+
+func TestResolveProperty(t *testing.T) {
+	var spec MiotSpecInstancesData
+	if err := json.Unmarshal([]byte(purifierSpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		siid      int
+		piid      int
+		ambiguous bool
+	}{
+		{name: "pm2.5-density", siid: 3, piid: 4},
+		{name: "PM2.5 Density", siid: 3, piid: 4},
+		{name: "pm2.5", siid: 3, piid: 4},
+		{name: "environment.pm2.5-density", siid: 3, piid: 4},
+		{name: "3.pm2.5-density", siid: 3, piid: 4},
+		{name: "3-4", siid: 3, piid: 4},
+		{name: "humidity", siid: 3, piid: 7},
+		{name: "air-purifier.mode", siid: 2, piid: 4},
+		{name: "2.on", siid: 2, piid: 1},
+		{name: "alarm.on", siid: 9, piid: 1},
+		{name: "on", ambiguous: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, p, err := spec.ResolveProperty(tt.name)
+			if tt.ambiguous {
+				if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+					t.Fatalf("got %v, want an ambiguous error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Iid != tt.siid || p.Iid != tt.piid {
+				t.Fatalf("got %d-%d, want %d-%d", s.Iid, p.Iid, tt.siid, tt.piid)
+			}
+		})
+	}
+}
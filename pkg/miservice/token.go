@@ -1,12 +1,31 @@
 package miservice
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"micli/pkg/secret"
+
+	"github.com/pterm/pterm"
 )
 
 type SidToken struct {
 	SSecurity    string `json:"ssecurity"`
 	ServiceToken string `json:"service_token"`
+	// IssuedAt is the unix time the service token was obtained, 0 when unknown
+	IssuedAt int64 `json:"issued_at,omitempty"`
+}
+
+// Age is the time since the service token was obtained, 0 when unknown
+func (t SidToken) Age() time.Duration {
+	if t.IssuedAt == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(t.IssuedAt, 0))
 }
 
 type Tokens struct {
@@ -30,6 +49,19 @@ func NewTokens() *Tokens {
 	}
 }
 
+// decodeTokens unmarshals a token JSON, a file without "sids" such as a
+// passToken-only $MI_TOKEN still gets a usable map
+func decodeTokens(data []byte) (*Tokens, error) {
+	tokens := NewTokens()
+	if err := json.Unmarshal(data, tokens); err != nil {
+		return nil, err
+	}
+	if tokens.Sids == nil {
+		tokens.Sids = make(map[string]SidToken)
+	}
+	return tokens, nil
+}
+
 type TokenStore interface {
 	LoadToken() (*Tokens, error)
 	SaveToken(tokens *Tokens) error
@@ -44,7 +76,6 @@ func NewTokenStore(tokenPath string) *FileTokenStore {
 }
 
 func (mts *FileTokenStore) LoadToken() (*Tokens, error) {
-	var tokens Tokens
 	if _, err := os.Stat(mts.tokenPath); os.IsNotExist(err) {
 		return nil, err
 	}
@@ -52,8 +83,7 @@ func (mts *FileTokenStore) LoadToken() (*Tokens, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(data, &tokens)
-	return &tokens, err
+	return decodeTokens(data)
 }
 
 func (mts *FileTokenStore) SaveToken(tokens *Tokens) (err error) {
@@ -75,3 +105,139 @@ func (mts *FileTokenStore) SaveToken(tokens *Tokens) (err error) {
 	}
 	return
 }
+
+// ErrReadOnlyTokenStore is returned by SaveToken of stores that can not be written.
+var ErrReadOnlyTokenStore = errors.New("token store is read-only")
+
+// isNoToken reports whether a LoadToken error only means that nothing is
+// stored yet, other errors such as a wrong passphrase leave the store alone
+func isNoToken(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, secret.ErrNotFound)
+}
+
+// saveToken writes s.token to the store, a failure is reported but the
+// session keeps working for this run
+func (s *Service) saveToken() {
+	if s.tokenStore == nil {
+		return
+	}
+	if err := s.tokenStore.SaveToken(s.token); err != nil && !errors.Is(err, ErrReadOnlyTokenStore) {
+		pterm.Warning.Printf("Fail to save the token, the next run has to log in again: %v\n", err)
+	}
+}
+
+// clearToken removes the stored token
+func (s *Service) clearToken() {
+	if s.tokenStore == nil {
+		return
+	}
+	if err := s.tokenStore.SaveToken(nil); err != nil && !isNoToken(err) && !errors.Is(err, ErrReadOnlyTokenStore) {
+		pterm.Warning.Printf("Fail to remove the token: %v\n", err)
+	}
+}
+
+// EncryptedFileTokenStore keeps the tokens in a file encrypted with AES-GCM.
+// The passphrase is asked for on first use.
+type EncryptedFileTokenStore struct {
+	tokenPath  string
+	passphrase func() (string, error)
+}
+
+func NewEncryptedTokenStore(tokenPath string, passphrase func() (string, error)) *EncryptedFileTokenStore {
+	return &EncryptedFileTokenStore{tokenPath: tokenPath, passphrase: passphrase}
+}
+
+func (s *EncryptedFileTokenStore) LoadToken() (*Tokens, error) {
+	data, err := os.ReadFile(s.tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	pass, err := s.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = secret.Open(pass, data); err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", s.tokenPath, err)
+	}
+	return decodeTokens(data)
+}
+
+func (s *EncryptedFileTokenStore) SaveToken(tokens *Tokens) error {
+	if tokens == nil {
+		if err := os.Remove(s.tokenPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	pass, err := s.passphrase()
+	if err != nil {
+		return err
+	}
+	if data, err = secret.Seal(pass, data); err != nil {
+		return err
+	}
+	return os.WriteFile(s.tokenPath, data, 0o600)
+}
+
+// KeyringTokenStore keeps the tokens in the OS keyring, see package secret.
+type KeyringTokenStore struct {
+	service string
+	account string
+}
+
+func NewKeyringTokenStore(service, account string) *KeyringTokenStore {
+	return &KeyringTokenStore{service: service, account: account}
+}
+
+func (s *KeyringTokenStore) LoadToken() (*Tokens, error) {
+	data, err := secret.Get(s.service, s.account)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTokens([]byte(data))
+}
+
+func (s *KeyringTokenStore) SaveToken(tokens *Tokens) error {
+	if tokens == nil {
+		return secret.Delete(s.service, s.account)
+	}
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	return secret.Set(s.service, s.account, string(data))
+}
+
+// EnvTokenStore reads the tokens from an environment variable holding the
+// JSON of a token file, plain or base64 encoded. It is read-only, new tokens
+// only live for the process, which suits CI.
+type EnvTokenStore struct {
+	name string
+}
+
+func NewEnvTokenStore(name string) *EnvTokenStore {
+	return &EnvTokenStore{name: name}
+}
+
+func (s *EnvTokenStore) LoadToken() (*Tokens, error) {
+	value := strings.TrimSpace(os.Getenv(s.name))
+	if value == "" {
+		return nil, fmt.Errorf("%s is not set: %w", s.name, os.ErrNotExist)
+	}
+	data := []byte(value)
+	if !strings.HasPrefix(value, "{") {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(value); err != nil {
+			return nil, fmt.Errorf("%s is neither JSON nor base64: %w", s.name, err)
+		}
+	}
+	return decodeTokens(data)
+}
+
+func (s *EnvTokenStore) SaveToken(*Tokens) error {
+	return ErrReadOnlyTokenStore
+}
@@ -0,0 +1,75 @@
+package miservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"micli/pkg/secret"
+)
+
+// passTokenOnly is a token JSON without "sids", as pasted into $MI_TOKEN on CI
+const passTokenOnly = `{"user_name":"user","user_id":"1001","pass_token":"x"}`
+
+// newPassportServer answers a passToken serviceLogin and the token exchange
+func newPassportServer(t *testing.T) *url.URL {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pass/serviceLogin":
+			if c, err := r.Cookie("passToken"); err != nil || c.Value != "x" {
+				_, _ = w.Write([]byte(`&&&START&&&{"code":70016}`))
+				return
+			}
+			_, _ = w.Write([]byte(`&&&START&&&{"code":0,"ssecurity":"c2VjdXJpdHk=","nonce":1,"location":"https://sts.api.io.mi.com/sts?d=1"}`))
+		case "/sts":
+			http.SetCookie(w, &http.Cookie{Name: "serviceToken", Value: "service-token"})
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	return target
+}
+
+func TestLoadTokenWithoutSids(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "token")
+	if err := os.WriteFile(plain, []byte(passTokenOnly), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := secret.Seal("passphrase", []byte(passTokenOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := filepath.Join(dir, "token.enc")
+	if err = os.WriteFile(encrypted, sealed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MI_TOKEN_TEST", passTokenOnly)
+
+	stores := map[string]TokenStore{
+		"file":      NewTokenStore(plain),
+		"encrypted": NewEncryptedTokenStore(encrypted, func() (string, error) { return "passphrase", nil }),
+		"env":       NewEnvTokenStore("MI_TOKEN_TEST"),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := New("user", "", "cn", store)
+			s.SetInteractive(false)
+			s.client.Transport = redirectTransport{target: newPassportServer(t)}
+
+			if err := s.RefreshSid(MiioSid); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.token.Sids[MiioSid].ServiceToken; got != "service-token" {
+				t.Fatalf("got service token %q", got)
+			}
+		})
+	}
+}
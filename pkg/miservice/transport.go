@@ -0,0 +1,91 @@
+package miservice
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"micli/pkg/miio"
+)
+
+const (
+	TransportCloud = "cloud"
+	TransportLocal = "local"
+)
+
+// ErrTransportUnavailable is returned by a transport that cannot reach the
+// device right now, i.e. a LAN timeout. IOService falls back to the cloud when it sees it.
+var ErrTransportUnavailable = errors.New("transport unavailable")
+
+// Transport carries MIoT spec requests (prop/get, prop/set, action) to a device.
+type Transport interface {
+	Name() string
+	MiotRequest(did, cmd string, params interface{}) (interface{}, error)
+}
+
+// CloudTransport sends requests through api.io.mi.com.
+type CloudTransport struct {
+	io *IOService
+}
+
+func (t *CloudTransport) Name() string {
+	return TransportCloud
+}
+
+func (t *CloudTransport) MiotRequest(_, cmd string, params interface{}) (interface{}, error) {
+	return t.io.MiotRequest(cmd, params)
+}
+
+// LocalLookup returns the LAN address and token of a device.
+type LocalLookup func(did string) (ip, token string, err error)
+
+// LocalTransport sends requests straight to the device over the LAN miIO protocol.
+type LocalTransport struct {
+	lookup  LocalLookup
+	mu      sync.Mutex
+	clients map[string]*miio.Client
+}
+
+func NewLocalTransport(lookup LocalLookup) *LocalTransport {
+	return &LocalTransport{
+		lookup:  lookup,
+		clients: make(map[string]*miio.Client),
+	}
+}
+
+func (t *LocalTransport) Name() string {
+	return TransportLocal
+}
+
+// Client returns the cached miIO client for a device, creating it on first use.
+func (t *LocalTransport) Client(did string) (*miio.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.clients[did]; ok {
+		return c, nil
+	}
+	// a device missing from the cache or without an address is a setup
+	// error, it must not quietly go to the cloud
+	ip, token, err := t.lookup(did)
+	if err != nil {
+		return nil, err
+	}
+	c, err := miio.New(ip, token)
+	if err != nil {
+		return nil, err
+	}
+	t.clients[did] = c
+	return c, nil
+}
+
+func (t *LocalTransport) MiotRequest(did, cmd string, params interface{}) (interface{}, error) {
+	c, err := t.Client(did)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.MiotRequest(cmd, params)
+	if errors.Is(err, miio.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %v", ErrTransportUnavailable, err)
+	}
+	return res, err
+}
@@ -0,0 +1,183 @@
+package miservice
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Codes of serviceLoginAuth2
+const (
+	loginCodeWrongPassword = 70016
+	loginCodeCaptcha       = 87001
+)
+
+// maxCaptchaTries is how many captcha answers a password login accepts
+const maxCaptchaTries = 3
+
+// Identity verification methods, the flags of /identity/list
+const (
+	VerifyPhone = "phone"
+	VerifyEmail = "email"
+)
+
+var verifyFlags = map[int]string{4: VerifyPhone, 8: VerifyEmail}
+
+// CaptchaHandler shows the captcha image of a password login and returns the text read from it
+type CaptchaHandler func(image []byte) (string, error)
+
+// VerifyHandler asks for the code Xiaomi sent to confirm a login from a new
+// device, method is VerifyPhone or VerifyEmail
+type VerifyHandler func(method string) (string, error)
+
+// SetCaptchaHandler sets who answers captchas, without one a captcha fails the login
+func (s *Service) SetCaptchaHandler(h CaptchaHandler) {
+	s.captchaHandler = h
+}
+
+// SetVerifyHandler sets who enters SMS or email codes, without one the
+// identity verification fails the login
+func (s *Service) SetVerifyHandler(h VerifyHandler) {
+	s.verifyHandler = h
+}
+
+// loginError describes a rejected serviceLoginAuth2
+func loginError(resp *loginResp) error {
+	if resp.Code == loginCodeWrongPassword {
+		return fmt.Errorf("wrong username or password (code %d)", resp.Code)
+	}
+	desc := resp.Desc
+	if desc == "" {
+		desc = resp.Description
+	}
+	return fmt.Errorf("login rejected: code=%d, desc=%s", resp.Code, desc)
+}
+
+// solveCaptcha downloads the captcha image and asks the CaptchaHandler for its
+// text, the ick cookie of the image stays in the jar for serviceLoginAuth2
+func (s *Service) solveCaptcha(captchaURL string) (string, error) {
+	if s.captchaHandler == nil || !s.interactive {
+		return "", ErrCaptchaRequired
+	}
+	if strings.HasPrefix(captchaURL, "/") {
+		captchaURL = "https://account.xiaomi.com" + captchaURL
+	}
+	image, err := s.accountRequest(http.MethodGet, captchaURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get captcha: %w", err)
+	}
+	return s.captchaHandler(image)
+}
+
+// verifyIdentity goes through the SMS or email ticket flow Xiaomi starts for
+// logins from new devices, the passToken ends up in the cookie jar
+func (s *Service) verifyIdentity(notificationURL, sid string) error {
+	if s.verifyHandler == nil || !s.interactive {
+		return ErrVerificationRequired
+	}
+	u, err := url.Parse(notificationURL)
+	if err != nil {
+		return fmt.Errorf("invalid notificationUrl: %w", err)
+	}
+	// opening the page starts the identity session
+	if _, err = s.accountRequest(http.MethodGet, notificationURL, nil); err != nil {
+		return err
+	}
+	var list struct {
+		Code    int   `json:"code"`
+		Flag    int   `json:"flag"`
+		Options []int `json:"options"`
+	}
+	q := url.Values{"sid": {sid}, "context": {u.Query().Get("context")}, "_locale": {"en_US"}}
+	if err = s.accountJSON(http.MethodGet, "https://account.xiaomi.com/identity/list?"+q.Encode(), nil, &list); err != nil {
+		return err
+	}
+	flag := list.Flag
+	for _, f := range []int{4, 8} {
+		if slices.Contains(list.Options, f) {
+			flag = f
+			break
+		}
+	}
+	method, ok := verifyFlags[flag]
+	if !ok {
+		return fmt.Errorf("%w: unsupported verification method %d", ErrVerificationRequired, flag)
+	}
+	// the endpoints are named sendPhoneTicket, verifyEmail...
+	name := strings.ToUpper(method[:1]) + method[1:]
+
+	var sent struct {
+		Code int    `json:"code"`
+		Desc string `json:"desc"`
+	}
+	sendURL := fmt.Sprintf("https://account.xiaomi.com/identity/auth/send%sTicket?_dc=%d", name, time.Now().UnixMilli())
+	if err = s.accountJSON(http.MethodPost, sendURL, url.Values{"retry": {"0"}, "icode": {""}, "_json": {"true"}}, &sent); err != nil {
+		return err
+	}
+	if sent.Code != 0 {
+		return fmt.Errorf("failed to send the %s code: code=%d, desc=%s", method, sent.Code, sent.Desc)
+	}
+
+	ticket, err := s.verifyHandler(method)
+	if err != nil {
+		return err
+	}
+	var verified struct {
+		Code     int    `json:"code"`
+		Desc     string `json:"desc"`
+		Location string `json:"location"`
+	}
+	verifyURL := fmt.Sprintf("https://account.xiaomi.com/identity/auth/verify%s?_flag=%d&_json=true", name, flag)
+	data := url.Values{"_flag": {fmt.Sprint(flag)}, "ticket": {strings.TrimSpace(ticket)}, "trust": {"true"}, "_json": {"true"}}
+	if err = s.accountJSON(http.MethodPost, verifyURL, data, &verified); err != nil {
+		return err
+	}
+	if verified.Code != 0 || verified.Location == "" {
+		return fmt.Errorf("verification code rejected: code=%d, desc=%s", verified.Code, verified.Desc)
+	}
+	// the redirects set the passToken cookie
+	_, err = s.accountRequest(http.MethodGet, verified.Location, nil)
+	return err
+}
+
+// accountRequest calls the Xiaomi account site with the cookie jar of the service
+func (s *Service) accountRequest(method, u string, form url.Values) ([]byte, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.buildUA())
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNetwork, err)
+	}
+	return data, nil
+}
+
+// accountJSON is accountRequest for the &&&START&&& prefixed JSON answers
+func (s *Service) accountJSON(method, u string, form url.Values, out any) error {
+	data, err := s.accountRequest(method, u, form)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal([]byte(strings.TrimPrefix(string(data), "&&&START&&&")), out); err != nil {
+		return fmt.Errorf("failed to parse %s: %w, body: %s", u, err, string(data))
+	}
+	return nil
+}
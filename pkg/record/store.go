@@ -0,0 +1,236 @@
+// Package record keeps sampled property values in a local SQLite database.
+package record
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS series (
+	did    TEXT    NOT NULL,
+	siid   INTEGER NOT NULL,
+	piid   INTEGER NOT NULL,
+	device TEXT    NOT NULL DEFAULT '',
+	name   TEXT    NOT NULL DEFAULT '',
+	unit   TEXT    NOT NULL DEFAULT '',
+	PRIMARY KEY (did, siid, piid)
+);
+CREATE TABLE IF NOT EXISTS samples (
+	did   TEXT    NOT NULL,
+	siid  INTEGER NOT NULL,
+	piid  INTEGER NOT NULL,
+	ts    INTEGER NOT NULL,
+	value REAL    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS samples_key ON samples (did, siid, piid, ts);
+`
+
+// Key identifies a recorded property.
+type Key struct {
+	Did  string `json:"did"`
+	Siid int    `json:"siid"`
+	Piid int    `json:"piid"`
+}
+
+// Series describes a recorded property, Count, First and Last are only set
+// by Store.Series.
+type Series struct {
+	Key
+	Device string     `json:"device"`
+	Name   string     `json:"name"`
+	Unit   string     `json:"unit,omitempty"`
+	Count  int        `json:"count"`
+	First  *time.Time `json:"first,omitempty"`
+	Last   *time.Time `json:"last,omitempty"`
+}
+
+// Sample is one value read at a point in time.
+type Sample struct {
+	Key
+	Time  time.Time
+	Value float64
+}
+
+// Point aggregates the samples of a time bucket.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+}
+
+// Store is a sample database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the database at path.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// one connection keeps the pragmas, WAL lets queries run while recording
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA busy_timeout = 5000", "PRAGMA journal_mode = WAL", schema} {
+		if _, err = db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("open %s: %w", path, err)
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// AddSeries records or updates the description of a property.
+func (s *Store) AddSeries(series *Series) error {
+	_, err := s.db.Exec(`INSERT INTO series (did, siid, piid, device, name, unit) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (did, siid, piid) DO UPDATE SET device = excluded.device, name = excluded.name, unit = excluded.unit`,
+		series.Did, series.Siid, series.Piid, series.Device, series.Name, series.Unit)
+	return err
+}
+
+// Add stores samples in one transaction.
+func (s *Store) Add(samples []*Sample) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO samples (did, siid, piid, ts, value) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer func(stmt *sql.Stmt) {
+		_ = stmt.Close()
+	}(stmt)
+	for _, sample := range samples {
+		if _, err = stmt.Exec(sample.Did, sample.Siid, sample.Piid, sample.Time.Unix(), sample.Value); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Series lists the recorded properties with their sample count and time span.
+func (s *Store) Series() ([]*Series, error) {
+	rows, err := s.db.Query(`SELECT s.did, s.siid, s.piid, s.device, s.name, s.unit, COUNT(v.ts), COALESCE(MIN(v.ts), 0), COALESCE(MAX(v.ts), 0)
+		FROM series s LEFT JOIN samples v ON v.did = s.did AND v.siid = s.siid AND v.piid = s.piid
+		GROUP BY s.did, s.siid, s.piid ORDER BY s.device, s.did, s.siid, s.piid`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	var list []*Series
+	for rows.Next() {
+		var (
+			series      Series
+			first, last int64
+		)
+		if err = rows.Scan(&series.Did, &series.Siid, &series.Piid, &series.Device, &series.Name, &series.Unit, &series.Count, &first, &last); err != nil {
+			return nil, err
+		}
+		if series.Count > 0 {
+			f, l := time.Unix(first, 0), time.Unix(last, 0)
+			series.First, series.Last = &f, &l
+		}
+		list = append(list, &series)
+	}
+	return list, rows.Err()
+}
+
+// Points returns the samples of key in [from, to) aggregated into buckets of
+// step, a step under a second returns every sample as a point of its own.
+func (s *Store) Points(key Key, from, to time.Time, step time.Duration) ([]*Point, error) {
+	query := `SELECT ts, 1, value, value, value FROM samples
+		WHERE did = ? AND siid = ? AND piid = ? AND ts >= ? AND ts < ?
+		ORDER BY ts, rowid`
+	args := []interface{}{key.Did, key.Siid, key.Piid, from.Unix(), to.Unix()}
+	if step >= time.Second {
+		size := int64(step / time.Second)
+		query = `SELECT ts / ? * ? AS bucket, COUNT(*), MIN(value), MAX(value), AVG(value) FROM samples
+		WHERE did = ? AND siid = ? AND piid = ? AND ts >= ? AND ts < ?
+		GROUP BY bucket ORDER BY bucket`
+		args = append([]interface{}{size, size}, args...)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	var points []*Point
+	for rows.Next() {
+		var (
+			p  Point
+			ts int64
+		)
+		if err = rows.Scan(&ts, &p.Count, &p.Min, &p.Max, &p.Avg); err != nil {
+			return nil, err
+		}
+		p.Time = time.Unix(ts, 0)
+		points = append(points, &p)
+	}
+	return points, rows.Err()
+}
+
+// Stats aggregates all samples of key in [from, to), the point time is the
+// first sample. It returns nil when there is no sample.
+func (s *Store) Stats(key Key, from, to time.Time) (*Point, error) {
+	var (
+		p  Point
+		ts sql.NullInt64
+	)
+	err := s.db.QueryRow(`SELECT MIN(ts), COUNT(*), COALESCE(MIN(value), 0), COALESCE(MAX(value), 0), COALESCE(AVG(value), 0) FROM samples
+		WHERE did = ? AND siid = ? AND piid = ? AND ts >= ? AND ts < ?`,
+		key.Did, key.Siid, key.Piid, from.Unix(), to.Unix()).Scan(&ts, &p.Count, &p.Min, &p.Max, &p.Avg)
+	if err != nil || !ts.Valid {
+		return nil, err
+	}
+	p.Time = time.Unix(ts.Int64, 0)
+	return &p, nil
+}
+
+// Value converts a property value to a sample value, booleans are 0 and 1.
+// Strings and other values can not be recorded.
+func Value(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,60 @@
+package record
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPoints(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "record.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	key := Key{Did: "1", Siid: 3, Piid: 4}
+	// 20s into a minute: two samples in the same second, then one every 30s
+	base := time.Unix(1700000000, 0)
+	var samples []*Sample
+	for _, v := range []struct {
+		offset time.Duration
+		value  float64
+	}{{0, 10}, {0, 20}, {30 * time.Second, 30}, {60 * time.Second, 40}, {90 * time.Second, 50}} {
+		samples = append(samples, &Sample{Key: key, Time: base.Add(v.offset), Value: v.value})
+	}
+	if err = store.Add(samples); err != nil {
+		t.Fatal(err)
+	}
+	from, to := base, base.Add(2*time.Minute)
+
+	raw, err := store.Points(key, from, to, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != len(samples) {
+		t.Fatalf("step 0: got %d points, want every one of the %d samples", len(raw), len(samples))
+	}
+	for i, p := range raw {
+		if p.Count != 1 || p.Avg != samples[i].Value || !p.Time.Equal(samples[i].Time) {
+			t.Fatalf("step 0: point %d is %+v, want sample %+v", i, p, samples[i])
+		}
+	}
+
+	buckets, err := store.Points(key, from, to, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Point{
+		{Time: base.Truncate(time.Minute), Count: 3, Min: 10, Max: 30, Avg: 20},
+		{Time: base.Truncate(time.Minute).Add(time.Minute), Count: 2, Min: 40, Max: 50, Avg: 45},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("step 1m: got %d points, want %d", len(buckets), len(want))
+	}
+	for i, w := range want {
+		if p := buckets[i]; !p.Time.Equal(w.Time) || p.Count != w.Count || p.Min != w.Min || p.Max != w.Max || p.Avg != w.Avg {
+			t.Fatalf("step 1m: point %d is %+v, want %+v", i, p, w)
+		}
+	}
+}
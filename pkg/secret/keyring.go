@@ -0,0 +1,97 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNotFound means the keyring has no secret for the service and account.
+var ErrNotFound = errors.New("secret not found in keyring")
+
+// The keyring is reached through the platform tools: secret-tool (freedesktop
+// Secret Service, e.g. GNOME Keyring or KWallet) and security on macOS.
+
+// Get reads a secret from the OS keyring.
+func Get(service, account string) (string, error) {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		c = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", errors.New("keyring is not supported on windows")
+	default:
+		c = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	}
+	out, err := run(c, nil)
+	if err != nil {
+		return "", err
+	}
+	// secret-tool exits 0 with no output when nothing matches
+	if out == "" {
+		return "", ErrNotFound
+	}
+	return out, nil
+}
+
+// Set stores a secret in the OS keyring, replacing the previous one.
+func Set(service, account, value string) error {
+	var (
+		c     *exec.Cmd
+		stdin []byte
+	)
+	switch runtime.GOOS {
+	case "darwin":
+		// security only takes the secret as an argument
+		c = exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", account, "-w", value)
+	case "windows":
+		return errors.New("keyring is not supported on windows")
+	default:
+		c = exec.Command("secret-tool", "store", "--label", service+" "+account, "service", service, "account", account)
+		stdin = []byte(value)
+	}
+	_, err := run(c, stdin)
+	return err
+}
+
+// Delete removes a secret from the OS keyring, a missing secret is not an error.
+func Delete(service, account string) error {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		c = exec.Command("security", "delete-generic-password", "-s", service, "-a", account)
+	case "windows":
+		return errors.New("keyring is not supported on windows")
+	default:
+		c = exec.Command("secret-tool", "clear", "service", service, "account", account)
+	}
+	_, err := run(c, nil)
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	return err
+}
+
+func run(c *exec.Cmd, stdin []byte) (string, error) {
+	var stdout, stderr bytes.Buffer
+	c.Stdin, c.Stdout, c.Stderr = bytes.NewReader(stdin), &stdout, &stderr
+	err := c.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "", fmt.Errorf("%s not found, install it to use the keyring", c.Args[0])
+	case errors.As(err, &exitErr):
+		msg := strings.TrimSpace(stderr.String())
+		// secret-tool lookup and clear exit 1 silently, security prints "could not be found"
+		if msg == "" || strings.Contains(msg, "could not be found") {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("%s: %s", c.Args[0], msg)
+	case err != nil:
+		return "", err
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
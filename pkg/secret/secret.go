@@ -0,0 +1,96 @@
+// Package secret encrypts small secrets with a passphrase and keeps them in
+// the OS keyring.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	kdfName = "pbkdf2-sha256"
+	kdfIter = 600000
+	// maxIter bounds the iterations read from a file, an edited or corrupted
+	// count must not keep the CLI busy for hours
+	maxIter = 4 * kdfIter
+)
+
+// ErrDecrypt means the passphrase is wrong or the data was changed.
+var ErrDecrypt = errors.New("wrong passphrase or corrupted data")
+
+// sealed is the stored form of an encrypted secret, the byte fields are
+// base64 in JSON.
+type sealed struct {
+	Version int    `json:"v"`
+	KDF     string `json:"kdf"`
+	Iter    int    `json:"iter"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Seal encrypts plaintext with AES-256-GCM under a key derived from
+// passphrase, the result is a small JSON document.
+func Seal(passphrase string, plaintext []byte) ([]byte, error) {
+	s := &sealed{Version: 1, KDF: kdfName, Iter: kdfIter, Salt: make([]byte, 16)}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, s.Salt, s.Iter)
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(s.Nonce); err != nil {
+		return nil, err
+	}
+	s.Data = gcm.Seal(nil, s.Nonce, plaintext, nil)
+	return json.Marshal(s)
+}
+
+// Open decrypts data produced by Seal.
+func Open(passphrase string, data []byte) ([]byte, error) {
+	var s sealed
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("not an encrypted secret: %w", err)
+	}
+	if s.Version != 1 || s.KDF != kdfName {
+		return nil, fmt.Errorf("unsupported secret format v%d %s", s.Version, s.KDF)
+	}
+	if s.Iter < 1 || s.Iter > maxIter {
+		return nil, fmt.Errorf("unsupported secret format: %d %s iterations", s.Iter, s.KDF)
+	}
+	gcm, err := newGCM(passphrase, s.Salt, s.Iter)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := gcm.Open(nil, s.Nonce, s.Data, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iter, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,78 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	plaintext := []byte(`{"pass_token":"x"}`)
+	data, err := Seal("passphrase", plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, plaintext) {
+		t.Fatal("sealed data holds the plaintext")
+	}
+	got, err := Open("passphrase", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %s, want %s", got, plaintext)
+	}
+
+	if _, err = Open("wrong", data); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong passphrase: got %v, want ErrDecrypt", err)
+	}
+	if _, err = Open("", data); err == nil {
+		t.Fatal("empty passphrase: got no error")
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	data, err := Seal("passphrase", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		change func(s *sealed)
+		want   string
+	}{
+		{name: "data", change: func(s *sealed) { s.Data[0] ^= 1 }, want: ErrDecrypt.Error()},
+		{name: "truncated data", change: func(s *sealed) { s.Data = s.Data[:len(s.Data)-1] }, want: ErrDecrypt.Error()},
+		{name: "nonce", change: func(s *sealed) { s.Nonce[0] ^= 1 }, want: ErrDecrypt.Error()},
+		{name: "short nonce", change: func(s *sealed) { s.Nonce = s.Nonce[:4] }, want: ErrDecrypt.Error()},
+		{name: "salt", change: func(s *sealed) { s.Salt[0] ^= 1 }, want: ErrDecrypt.Error()},
+		{name: "version", change: func(s *sealed) { s.Version = 2 }, want: "unsupported secret format"},
+		{name: "kdf", change: func(s *sealed) { s.KDF = "md5" }, want: "unsupported secret format"},
+		{name: "zero iterations", change: func(s *sealed) { s.Iter = 0 }, want: "iterations"},
+		{name: "negative iterations", change: func(s *sealed) { s.Iter = -1 }, want: "iterations"},
+		{name: "huge iterations", change: func(s *sealed) { s.Iter = 1 << 40 }, want: "iterations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s sealed
+			if err := json.Unmarshal(data, &s); err != nil {
+				t.Fatal(err)
+			}
+			tt.change(&s)
+			edited, err := json.Marshal(&s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Open("passphrase", edited)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err = Open("passphrase", []byte("not json")); err == nil {
+		t.Fatal("not JSON: got no error")
+	}
+}
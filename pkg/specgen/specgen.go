@@ -0,0 +1,315 @@
+// Package specgen turns MIoT spec instances into typed Go device clients.
+package specgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"micli/pkg/miservice"
+)
+
+// Options control the generated package.
+type Options struct {
+	Package string
+	Model   string
+}
+
+type file struct {
+	Package     string
+	Model       string
+	Type        string
+	Description string
+	Services    []*service
+}
+
+type service struct {
+	Name        string
+	Field       string
+	Description string
+	Siid        int
+	Props       []*prop
+	Actions     []*action
+	Enums       []*enum
+}
+
+type prop struct {
+	Name        string
+	Short       string
+	Description string
+	Piid        int
+	GoType      string
+	Format      string
+	Access      string
+	Read        bool
+	Write       bool
+	Enum        *enum
+	Range       *valueRange
+	Setter      *param
+}
+
+type valueRange struct {
+	Min, Max, Step string
+	Integer        bool
+}
+
+type enum struct {
+	Type   string
+	Prop   string
+	Base   string
+	Values []*enumValue
+}
+
+type enumValue struct {
+	Name        string
+	Value       int
+	Description string
+}
+
+type action struct {
+	Name        string
+	Description string
+	Aiid        int
+	Params      []*param
+	Out         bool
+}
+
+type param struct {
+	Name   string
+	GoType string
+	Piid   int
+	Prop   *prop
+	// Fail prefixes the error of a failed check, "nil, " when the method also returns values
+	Fail string
+}
+
+// reserved are the identifiers a generated method can not use for a parameter.
+var reserved = map[string]bool{
+	"ctx": true, "s": true, "context": true, "fmt": true, "math": true, "reflect": true, "miservice": true,
+}
+
+// PackageName derives a package name from a model, xiaomi.wifispeaker.lx06 becomes lx06.
+func PackageName(model string) string {
+	if parts := strings.Split(model, ":"); len(parts) > 5 {
+		model = parts[5]
+	}
+	parts := strings.Split(model, ".")
+	name := strings.ToLower(identifier(parts[len(parts)-1], ""))
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "device" + name
+	}
+	return name
+}
+
+// Generate writes a gofmt'ed Go package for spec to w.
+func Generate(w io.Writer, spec *miservice.MiotSpecInstancesData, opts Options) error {
+	if opts.Package == "" {
+		opts.Package = PackageName(opts.Model)
+	}
+	f := &file{
+		Package:     opts.Package,
+		Model:       opts.Model,
+		Type:        spec.Type,
+		Description: spec.Description,
+	}
+	fields := make(map[string]int)
+	for _, s := range spec.Services {
+		if len(s.Properties) == 0 && len(s.Actions) == 0 {
+			continue
+		}
+		f.Services = append(f.Services, newService(s, fields))
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, f); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format generated code: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func newService(s *miservice.MiotSpecService, fields map[string]int) *service {
+	name := identifier(miservice.ShortName(s.Type), s.Description)
+	if name == "" {
+		name = fmt.Sprintf("Service%d", s.Iid)
+	}
+	// two services of the same kind, e.g. a main and an ambient light
+	if fields[name]++; fields[name] > 1 {
+		name = fmt.Sprintf("%s%d", name, s.Iid)
+	}
+	srv := &service{Name: name, Field: name, Description: s.Description, Siid: s.Iid}
+	names := make(map[string]int)
+	props := make(map[int]*prop, len(s.Properties))
+	for _, p := range s.Properties {
+		pn := uniqueName(identifier(miservice.ShortName(p.Type), p.Description), fmt.Sprintf("Prop%d", p.Iid), p.Iid, names)
+		gp := &prop{
+			Name:        pn,
+			Short:       miservice.ShortName(p.Type),
+			Description: p.Description,
+			Piid:        p.Iid,
+			GoType:      goType(p.Format),
+			Format:      p.Format,
+			Access:      strings.Join(p.Access, ","),
+			Read:        p.Readable(),
+			Write:       p.Writable(),
+		}
+		if gp.Short == "" {
+			gp.Short = paramName(pn)
+		}
+		gp.Setter = &param{Name: paramName(pn), GoType: gp.GoType, Piid: p.Iid, Prop: gp}
+		if len(p.ValueList) > 0 {
+			gp.Enum = newEnum(name+pn, pn, gp.GoType, p)
+			gp.GoType = gp.Enum.Type
+			srv.Enums = append(srv.Enums, gp.Enum)
+			gp.Setter.GoType = gp.GoType
+		} else if r := newRange(p); r != nil && gp.GoType != "bool" && gp.GoType != "string" && gp.GoType != "interface{}" {
+			gp.Range = r
+		}
+		props[p.Iid] = gp
+		srv.Props = append(srv.Props, gp)
+	}
+	for _, p := range srv.Props {
+		names["Get"+p.Name]++
+		names["Set"+p.Name]++
+	}
+	for _, a := range s.Actions {
+		an := uniqueName(identifier(miservice.ShortName(a.Type), a.Description), fmt.Sprintf("Action%d", a.Iid), a.Iid, names)
+		ga := &action{Name: an, Description: a.Description, Aiid: a.Iid, Out: len(a.Out) > 0}
+		params := make(map[string]int)
+		for i, in := range a.In {
+			pr := props[in]
+			gt, pname := "interface{}", fmt.Sprintf("in%d", i+1)
+			if pr != nil {
+				gt = pr.GoType
+				pname = paramName(pr.Name)
+			}
+			if params[pname]++; params[pname] > 1 {
+				pname = fmt.Sprintf("%s%d", pname, i+1)
+			}
+			pa := &param{Name: pname, GoType: gt, Piid: in, Prop: pr}
+			if ga.Out {
+				pa.Fail = "nil, "
+			}
+			ga.Params = append(ga.Params, pa)
+		}
+		srv.Actions = append(srv.Actions, ga)
+	}
+	return srv
+}
+
+func newEnum(typeName, propName, base string, p *miservice.MiotSpecProperty) *enum {
+	if base == "bool" || base == "string" || base == "interface{}" || base == "float64" {
+		base = "int"
+	}
+	e := &enum{Type: typeName, Prop: propName, Base: base}
+	names := make(map[string]int)
+	seen := make(map[int]bool)
+	for _, v := range p.ValueList {
+		if seen[v.Value] {
+			continue
+		}
+		seen[v.Value] = true
+		if v.Value < 0 && strings.HasPrefix(e.Base, "uint") {
+			e.Base = "int"
+		}
+		num := strings.Replace(strconv.Itoa(v.Value), "-", "Neg", 1)
+		n := identifier(v.Description, "")
+		if n == "" {
+			n = "Value" + num
+		}
+		if names[n]++; names[n] > 1 {
+			n += num
+		}
+		e.Values = append(e.Values, &enumValue{Name: typeName + n, Value: v.Value, Description: v.Description})
+	}
+	return e
+}
+
+func newRange(p *miservice.MiotSpecProperty) *valueRange {
+	if len(p.ValueRange) < 2 {
+		return nil
+	}
+	r := &valueRange{
+		Min:     fmt.Sprint(p.ValueRange[0]),
+		Max:     fmt.Sprint(p.ValueRange[1]),
+		Step:    "0",
+		Integer: p.Format != "float",
+	}
+	if len(p.ValueRange) > 2 {
+		r.Step = fmt.Sprint(p.ValueRange[2])
+	}
+	return r
+}
+
+func goType(format string) string {
+	switch format {
+	case "bool", "string", "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64":
+		return format
+	case "float":
+		return "float64"
+	}
+	return "interface{}"
+}
+
+func uniqueName(name, fallback string, iid int, names map[string]int) string {
+	if name == "" {
+		name = fallback
+	}
+	if names[name]++; names[name] > 1 {
+		name = fmt.Sprintf("%s%d", name, iid)
+	}
+	return name
+}
+
+// identifier turns "play-text" or "Play Text" into PlayText, falling back to
+// the second name when the first has no usable characters.
+func identifier(name, fallback string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	res := b.String()
+	if res == "" && fallback != "" {
+		return identifier(fallback, "")
+	}
+	if res != "" && unicode.IsDigit(rune(res[0])) {
+		res = "N" + res
+	}
+	return res
+}
+
+// paramName is the lower camel case form of a property name, usable as a parameter.
+func paramName(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	name := string(r)
+	if token.IsKeyword(name) || reserved[name] {
+		name += "Value"
+	}
+	return name
+}
+
+var tmpl = template.Must(template.New("client").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"comment": func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	},
+}).Parse(clientTemplate))
@@ -0,0 +1,121 @@
+package specgen
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"micli/pkg/miservice"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// usage calls the API expected from testdata/purifier.json, so a renamed
+// method or a changed signature fails to compile like broken generated code
+const usage = `package purifier
+
+import "context"
+
+func use(ctx context.Context, d *Device) {
+	_ = d.AirPurifier.SetOn(ctx, true)
+	_ = d.AirPurifier.SetMode(ctx, AirPurifierModeFavorite)
+	_ = AirPurifierModeAuto3.String()
+	_ = d.AirPurifier.SetFanLevel(ctx, uint8(2))
+	_ = d.AirPurifier.Toggle(ctx)
+	var pm float64
+	pm, _ = d.Environment.GetPm25Density(ctx)
+	_ = pm
+	_, _ = d.DeviceInformation.GetFirmwareRevision(ctx)
+	var out []interface{}
+	out, _ = d.Filter.ResetFilterLife(ctx, int32(0))
+	_ = out
+	_, _ = d.Alarm.GetAlarm(ctx)
+}
+`
+
+func loadSpec(t *testing.T, name string) *miservice.MiotSpecInstancesData {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec miservice.MiotSpecInstancesData
+	if err = jsoniter.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	return &spec
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated package with the go command")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	var buf bytes.Buffer
+	err = Generate(&buf, loadSpec(t, "purifier.json"), Options{Package: "purifier", Model: "zhimi.airpurifier.ma4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// inside the module so that micli/pkg/miservice resolves, the leading
+	// underscore keeps ./... patterns away from it
+	dir, err := os.MkdirTemp(".", "_gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	if err = os.WriteFile(filepath.Join(dir, "purifier.go"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "usage.go"), []byte(usage), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goBin, "vet", "./"+filepath.Base(dir))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s\n%s", err, out, buf.String())
+	}
+}
+
+func TestGenerateChecks(t *testing.T) {
+	var buf bytes.Buffer
+	err := Generate(&buf, loadSpec(t, "purifier.json"), Options{Model: "zhimi.airpurifier.ma4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	for _, want := range []string{
+		"package ma4\n",
+		`const Model = "zhimi.airpurifier.ma4"`,
+		"return get[AirPurifierMode](ctx, s.c, 2, 4)",
+		"if !mode.Valid() {",
+		"return s.c.set(ctx, 4, 3, filterUsedTime)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code lacks %q", want)
+		}
+	}
+	// the service without properties or actions gets no client
+	if strings.Contains(src, "CustomService") {
+		t.Error("generated a client for an empty service")
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	tests := map[string]string{
+		"xiaomi.wifispeaker.lx06":                                   "lx06",
+		"zhimi.airpurifier.ma4":                                     "ma4",
+		"urn:miot-spec-v2:device:air-purifier:0000A007:zhimi-ma4:1": "zhimima4",
+		"lumi.sensor.3in1":                                          "n3in1",
+	}
+	for model, want := range tests {
+		if got := PackageName(model); got != want {
+			t.Errorf("PackageName(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
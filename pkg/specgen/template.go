@@ -0,0 +1,209 @@
+package specgen
+
+const clientTemplate = `// Code generated by micli spec gen; DO NOT EDIT.
+
+// Package {{.Package}} is a typed client for {{if .Model}}{{.Model}}{{else}}{{.Type}}{{end}}{{if .Description}}, {{comment .Description}}{{end}}.
+package {{.Package}}
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+
+	"micli/pkg/miservice"
+)
+
+// Type is the MIoT spec the client was generated from.
+const Type = {{quote .Type}}
+{{if .Model}}
+// Model is the device model the client was generated for.
+const Model = {{quote .Model}}
+{{end}}
+// Device groups the services of one device.
+type Device struct {
+{{- range .Services}}
+	{{.Field}} *{{.Name}}Service
+{{- end}}
+}
+
+// New returns a client for the device did, requests go through io.
+func New(io *miservice.IOService, did string) *Device {
+	c := &client{io: io, did: did}
+	return &Device{
+{{- range .Services}}
+		{{.Field}}: &{{.Name}}Service{c: c},
+{{- end}}
+	}
+}
+{{range $s := .Services}}
+// {{.Name}}Service is {{if .Description}}{{comment .Description}}, {{end}}siid {{.Siid}}.
+type {{.Name}}Service struct {
+	c *client
+}
+{{range $e := .Enums}}
+// {{.Type}} is a value of {{$s.Name}}.{{.Prop}}.
+type {{.Type}} {{.Base}}
+
+const (
+{{- range .Values}}
+	{{.Name}} {{$e.Type}} = {{.Value}}{{if .Description}} // {{comment .Description}}{{end}}
+{{- end}}
+)
+
+// String returns the spec description of the value.
+func (v {{.Type}}) String() string {
+	switch v {
+{{- range .Values}}
+	case {{.Name}}:
+		return {{quote .Description}}
+{{- end}}
+	}
+	return fmt.Sprintf("{{.Type}}(%d)", {{.Base}}(v))
+}
+
+// Valid reports whether v is in the value list of the spec.
+func (v {{.Type}}) Valid() bool {
+	switch v {
+	case {{range $i, $v := .Values}}{{if $i}}, {{end}}{{$v.Name}}{{end}}:
+		return true
+	}
+	return false
+}
+{{end}}
+{{- range .Props}}
+{{- if .Read}}
+// Get{{.Name}} reads {{if .Description}}{{comment .Description}}{{else}}{{.Name}}{{end}} (piid {{.Piid}}, {{.Format}}, {{.Access}}).
+func (s *{{$s.Name}}Service) Get{{.Name}}(ctx context.Context) ({{.GoType}}, error) {
+	return get[{{.GoType}}](ctx, s.c, {{$s.Siid}}, {{.Piid}})
+}
+{{end}}
+{{- if .Write}}
+// Set{{.Name}} writes {{if .Description}}{{comment .Description}}{{else}}{{.Name}}{{end}} (piid {{.Piid}}, {{.Format}}, {{.Access}}).
+{{- with .Range}}
+// The value must be within [{{.Min}}, {{.Max}}]{{if ne .Step "0"}} in steps of {{.Step}}{{end}}.
+{{- end}}
+func (s *{{$s.Name}}Service) Set{{.Name}}(ctx context.Context, {{.Setter.Name}} {{.GoType}}) error {
+{{- template "check" .Setter}}
+	return s.c.set(ctx, {{$s.Siid}}, {{.Piid}}, {{.Setter.Name}})
+}
+{{end}}
+{{- end}}
+{{- range .Actions}}
+// {{.Name}} runs {{if .Description}}{{comment .Description}}{{else}}{{.Name}}{{end}} (aiid {{.Aiid}}).
+{{- if .Out}}
+// It returns the out values of the action.
+func (s *{{$s.Name}}Service) {{.Name}}(ctx context.Context{{range .Params}}, {{.Name}} {{.GoType}}{{end}}) ([]interface{}, error) {
+{{- range .Params}}{{template "check" .}}{{end}}
+	return s.c.action(ctx, {{$s.Siid}}, {{.Aiid}}{{range .Params}}, {{.Name}}{{end}})
+}
+{{- else}}
+func (s *{{$s.Name}}Service) {{.Name}}(ctx context.Context{{range .Params}}, {{.Name}} {{.GoType}}{{end}}) error {
+{{- range .Params}}{{template "check" .}}{{end}}
+	_, err := s.c.action(ctx, {{$s.Siid}}, {{.Aiid}}{{range .Params}}, {{.Name}}{{end}})
+	return err
+}
+{{- end}}
+{{end}}
+{{- end}}
+type client struct {
+	io  *miservice.IOService
+	did string
+}
+
+func get[T any](ctx context.Context, c *client, siid, piid int) (T, error) {
+	var out T
+	if err := ctx.Err(); err != nil {
+		return out, err
+	}
+	v, err := c.io.MiotGetProp(c.did, []interface{}{siid, piid})
+	if err != nil {
+		return out, err
+	}
+	return out, decode(v, &out)
+}
+
+func (c *client) set(ctx context.Context, siid, piid int, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := c.io.MiotSetProp(c.did, []interface{}{siid, piid, v})
+	return err
+}
+
+func (c *client) action(ctx context.Context, siid, aiid int, in ...interface{}) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	res, err := c.io.MiotAction(c.did, []int{siid, aiid}, in)
+	if res == nil {
+		return nil, err
+	}
+	return res.Out, err
+}
+
+// decode stores a JSON decoded value into out.
+func decode(v interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out).Elem()
+	switch rv.Kind() {
+	case reflect.Interface:
+		if v != nil {
+			rv.Set(reflect.ValueOf(v))
+		}
+		return nil
+	case reflect.String:
+		rv.SetString(fmt.Sprint(v))
+		return nil
+	case reflect.Bool:
+		switch b := v.(type) {
+		case bool:
+			rv.SetBool(b)
+			return nil
+		case float64:
+			rv.SetBool(b != 0)
+			return nil
+		}
+	default:
+		f, ok := v.(float64)
+		if !ok {
+			break
+		}
+		switch {
+		case rv.CanInt():
+			rv.SetInt(int64(f))
+		case rv.CanUint():
+			rv.SetUint(uint64(f))
+		case rv.CanFloat():
+			rv.SetFloat(f)
+		}
+		return nil
+	}
+	return fmt.Errorf("unexpected value %v (%T) for %s", v, v, rv.Type())
+}
+
+// checkRange refuses values outside [min, max] or off the step grid.
+func checkRange(v, min, max, step float64) error {
+	if v < min || v > max {
+		return fmt.Errorf("%v is out of range [%v, %v]", v, min, max)
+	}
+	if step > 0 {
+		if n := (v - min) / step; math.Abs(n-math.Round(n)) > 1e-9 {
+			return fmt.Errorf("%v is not a multiple of %v from %v", v, step, min)
+		}
+	}
+	return nil
+}
+{{define "check"}}
+{{- with .Prop}}
+{{- if .Enum}}
+	if !{{$.Name}}.Valid() {
+		return {{$.Fail}}fmt.Errorf("{{.Short}}: invalid value %v", {{$.Name}})
+	}
+{{- else if .Range}}
+	if err := checkRange({{if eq $.GoType "float64"}}{{$.Name}}{{else}}float64({{$.Name}}){{end}}, {{.Range.Min}}, {{.Range.Max}}, {{.Range.Step}}); err != nil {
+		return {{$.Fail}}fmt.Errorf("{{.Short}}: %w", err)
+	}
+{{- end}}
+{{- end}}
+{{- end}}
+`
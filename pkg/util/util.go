@@ -155,7 +155,11 @@ func StringToValue(str string) interface{} {
 	}
 }
 
+// StringOrValue converts digits to a number, a leading '#' keeps the rest as a string.
 func StringOrValue(str string) interface{} {
+	if strings.HasPrefix(str, "#") {
+		return str[1:]
+	}
 	if IsDigit(str) {
 		return StringToValue(str)
 	}